@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ossyrian/mintyparse/internal/parser"
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+// debugCmd groups hidden commands for reverse-engineering WZ files
+var debugCmd = &cobra.Command{
+	Use:    "debug",
+	Short:  "Debugging tools for reverse-engineering WZ files",
+	Hidden: true,
+}
+
+// debugOffsetsCmd prints the offset decryption inputs and outputs of every directory entry
+var debugOffsetsCmd = &cobra.Command{
+	Use:   "offsets",
+	Short: "Print raw and decrypted offsets for every directory entry",
+	Long: `Walks the directory tree (not images) and prints, for every entry, the
+inputs to offset decryption (position, body offset, version hash, encrypted
+offset) and the decrypted offset, along with its size and checksum. Useful
+when a version is slightly wrong and offsets are near-but-not-quite valid.`,
+	Args: cobra.NoArgs,
+	RunE: runDebugOffsets,
+}
+
+func init() {
+	debugOffsetsCmd.Flags().Bool("csv", false, "write CSV instead of a table")
+
+	debugCmd.AddCommand(debugOffsetsCmd)
+	rootCmd.AddCommand(debugCmd)
+}
+
+// runDebugOffsets runs the debug offsets command
+func runDebugOffsets(cmd *cobra.Command, args []string) error {
+	// logs share stdout with the table, so only surface problems
+	if err := loadConfig("warn"); err != nil {
+		return err
+	}
+	asCSV, _ := cmd.Flags().GetBool("csv")
+
+	file, err := openInput(cfg)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	result, err := parser.Parse(file, cfg)
+	if result == nil {
+		return err
+	}
+
+	header := []string{"path", "type", "size", "checksum", "offset_pos", "body_offset", "version_hash", "encrypted_offset", "data_offset"}
+	var rows [][]string
+	result.Root.Walk(func(path string, e *wz.DirEntryMetadata) error {
+		rows = append(rows, []string{
+			path,
+			entryTypeName(e.Type),
+			strconv.Itoa(int(e.FileSize)),
+			strconv.Itoa(int(e.Checksum)),
+			formatOffset(e.OffsetPos, asCSV),
+			formatOffset(result.Header.BodyOffset, asCSV),
+			formatOffset(result.VersionHash, asCSV),
+			formatOffset(e.EncryptedOffset, asCSV),
+			formatOffset(e.DataOffset, asCSV),
+		})
+		return nil
+	})
+
+	if asCSV {
+		w := csv.NewWriter(os.Stdout)
+		w.Write(header)
+		w.WriteAll(rows)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		writeTabRow(w, header)
+		for _, row := range rows {
+			writeTabRow(w, row)
+		}
+		w.Flush()
+	}
+
+	// report a partial walk (with --continue-on-error) after printing it
+	return err
+}
+
+// entryTypeName returns a short name for a directory entry type
+func entryTypeName(t wz.DirEntryType) string {
+	switch t {
+	case wz.DirEntryTypeDir:
+		return "dir"
+	case wz.DirEntryTypeFile:
+		return "file"
+	default:
+		return strconv.Itoa(int(t))
+	}
+}
+
+// formatOffset formats an offset as hex for tables and decimal for CSV
+func formatOffset(v uint32, asCSV bool) string {
+	if asCSV {
+		return strconv.FormatUint(uint64(v), 10)
+	}
+	return fmt.Sprintf("0x%08X", v)
+}
+
+// writeTabRow writes a tab-separated row to a tabwriter
+func writeTabRow(w io.Writer, row []string) {
+	for i, col := range row {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		fmt.Fprint(w, col)
+	}
+	fmt.Fprintln(w)
+}
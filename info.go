@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ossyrian/mintyparse/internal/parser"
+)
+
+// maxHexdumpHeader caps how much of the header --hexdump-header prints,
+// since a corrupt BodyOffset can claim a header of up to 4 GiB
+const maxHexdumpHeader = 64 << 10
+
+// infoCmd prints the file header without reading the directory tree
+var infoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Print the header of the input file",
+	Long: `Reads and prints the file header (magic, body size, body offset and
+copyright) without determining the version or reading the directory tree.
+With --hexdump-header, also prints a hex+ASCII dump of the whole header
+region, for comparing headers across clients.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runInfo,
+}
+
+func init() {
+	infoCmd.Flags().Bool("hexdump-header", false, "also print a hex dump of the header region (up to 64 KiB)")
+
+	rootCmd.AddCommand(infoCmd)
+}
+
+// runInfo runs the info command
+func runInfo(cmd *cobra.Command, args []string) error {
+	// logs share stdout with the header, so only surface problems
+	if err := loadConfig("warn"); err != nil {
+		return err
+	}
+	hexdump, _ := cmd.Flags().GetBool("hexdump-header")
+
+	file, err := openInput(cfg)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header, err := parser.NewWzReader(file, cfg, slog.Default()).ReadHeader()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("magic:       %s\n", header.Magic[:])
+	fmt.Printf("body_size:   %d\n", header.BodySize)
+	fmt.Printf("body_offset: %d\n", header.BodyOffset)
+	fmt.Printf("copyright:   %q\n", header.Copyright)
+
+	if !hexdump {
+		return nil
+	}
+
+	// ReadHeader only keeps the copyright, so re-read the region
+	size := min(int64(header.BodyOffset), maxHexdumpHeader)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind input: %w", err)
+	}
+
+	fmt.Println()
+	dumper := hex.Dumper(os.Stdout)
+	if _, err := io.CopyN(dumper, file, size); err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	dumper.Close()
+
+	if size < int64(header.BodyOffset) {
+		fmt.Printf("(truncated to %d of %d bytes)\n", size, header.BodyOffset)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// remoteBlockSize is how much OpenURL fetches per range request. The
+// parser reads a few bytes at a time, so reads are served from cached
+// blocks rather than issuing a request each.
+const remoteBlockSize = 64 << 10
+
+// ErrRangeUnsupported is returned by OpenURL when the server ignores
+// range requests.
+var ErrRangeUnsupported = errors.New("server doesn't support range requests")
+
+// OpenURL opens the file at url (http or https) for random access.
+//
+// Nothing is downloaded up front: the file is fetched in blocks with
+// HTTP range requests as the parser touches them, and each block is
+// fetched once. This makes reading a few directories or a single image
+// out of a large hosted file cheap, while a full parse ends up fetching
+// about the whole file.
+func OpenURL(client *http.Client, url string) (io.ReadSeekCloser, error) {
+	f := &remoteFile{
+		client: client,
+		url:    url,
+		blocks: make(map[int64][]byte),
+	}
+
+	// fetching the first block also finds the size, from Content-Range
+	block, size, err := f.fetch(0)
+	if err != nil {
+		return nil, err
+	}
+	f.size = size
+	f.blocks[0] = block
+
+	return &remoteReader{SectionReader: io.NewSectionReader(f, 0, size), f: f}, nil
+}
+
+// remoteFile is an io.ReaderAt over HTTP range requests.
+type remoteFile struct {
+	client *http.Client
+	url    string
+	size   int64
+
+	mu     sync.Mutex
+	blocks map[int64][]byte // by block index
+}
+
+func (f *remoteFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && off < f.size {
+		block, err := f.block(off / remoteBlockSize)
+		if err != nil {
+			return n, err
+		}
+		c := copy(p[n:], block[off%remoteBlockSize:])
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// block returns block i, fetching it if it isn't cached.
+func (f *remoteFile) block(i int64) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if block, ok := f.blocks[i]; ok {
+		return block, nil
+	}
+	block, _, err := f.fetch(i)
+	if err != nil {
+		return nil, err
+	}
+	f.blocks[i] = block
+	return block, nil
+}
+
+// fetch requests block i and returns it along with the total file size
+// reported by the server.
+func (f *remoteFile) fetch(i int64) ([]byte, int64, error) {
+	start := i * remoteBlockSize
+	end := start + remoteBlockSize - 1
+
+	req, err := http.NewRequest(http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch %s: %w", f.url, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return nil, 0, fmt.Errorf("%w: %s", ErrRangeUnsupported, f.url)
+	default:
+		return nil, 0, fmt.Errorf("failed to fetch %s: %s", f.url, resp.Status)
+	}
+
+	size, err := parseContentRangeSize(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch %s: %w", f.url, err)
+	}
+
+	// the last block is short
+	want := min(end+1, size) - start
+	block := make([]byte, want)
+	if _, err := io.ReadFull(resp.Body, block); err != nil {
+		return nil, 0, fmt.Errorf("failed to read bytes %d-%d of %s: %w", start, start+want-1, f.url, err)
+	}
+
+	return block, size, nil
+}
+
+// parseContentRangeSize returns the total size from a Content-Range
+// header such as "bytes 0-65535/1048576".
+func parseContentRangeSize(header string) (int64, error) {
+	_, total, ok := strings.Cut(header, "/")
+	if !ok || !strings.HasPrefix(header, "bytes ") {
+		return 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unknown file size in Content-Range %q", header)
+	}
+	return size, nil
+}
+
+// remoteReader is a file opened with OpenURL.
+type remoteReader struct {
+	*io.SectionReader
+	f *remoteFile
+}
+
+func (r *remoteReader) Close() error {
+	r.f.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,83 @@
+package archive_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ossyrian/mintyparse/internal/archive"
+)
+
+func TestOpenURL(t *testing.T) {
+	data := make([]byte, 200<<10)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.ServeContent(w, r, "Base.wz", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	rs, err := archive.OpenURL(srv.Client(), srv.URL+"/Base.wz")
+	if err != nil {
+		t.Fatalf("OpenURL() failed: %v", err)
+	}
+	defer rs.Close()
+
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(data)) {
+		t.Errorf("size = %d, want %d", size, len(data))
+	}
+
+	// a read straddling the second and third blocks, then one past the end
+	off := int64(128<<10 - 10)
+	if _, err := rs.Seek(off, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 20)
+	if _, err := io.ReadFull(rs, got); err != nil {
+		t.Fatalf("ReadFull() failed: %v", err)
+	}
+	if !bytes.Equal(got, data[off:off+20]) {
+		t.Errorf("read %x, want %x", got, data[off:off+20])
+	}
+
+	if _, err := rs.Seek(-5, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	tail, err := io.ReadAll(rs)
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	if !bytes.Equal(tail, data[len(data)-5:]) {
+		t.Errorf("tail = %x, want %x", tail, data[len(data)-5:])
+	}
+
+	// blocks 0 (on open), 1, 2 and 3; the second block was only fetched once
+	if n := requests.Load(); n != 4 {
+		t.Errorf("made %d requests, want 4", n)
+	}
+}
+
+func TestOpenURL_RangeUnsupported(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("PKG1 whole file"))
+	}))
+	defer srv.Close()
+
+	_, err := archive.OpenURL(srv.Client(), srv.URL)
+	if !errors.Is(err, archive.ErrRangeUnsupported) {
+		t.Errorf("OpenURL() error = %v, want %v", err, archive.ErrRangeUnsupported)
+	}
+}
@@ -0,0 +1,37 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Prefetch reads all of f into memory so that parsing is served from RAM
+// rather than issuing a read syscall per field.
+//
+// f is only read if its size is at most limit bytes. On success f is
+// closed and the in-memory copy is returned with ok set; if f is too big
+// it is returned unchanged (rewound to the start) with ok unset.
+func Prefetch(f io.ReadSeekCloser, limit int64) (rs io.ReadSeekCloser, ok bool, err error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get input size: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, false, fmt.Errorf("failed to rewind input: %w", err)
+	}
+
+	if size > limit {
+		return f, false, nil
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, false, fmt.Errorf("failed to prefetch input: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, false, err
+	}
+
+	return memFile{bytes.NewReader(data)}, true, nil
+}
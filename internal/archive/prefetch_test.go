@@ -0,0 +1,127 @@
+package archive_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ossyrian/mintyparse/internal/archive"
+	"github.com/ossyrian/mintyparse/internal/config"
+	"github.com/ossyrian/mintyparse/internal/parser"
+	"github.com/ossyrian/mintyparse/internal/wz"
+	"github.com/ossyrian/mintyparse/internal/wztest"
+)
+
+func TestPrefetch(t *testing.T) {
+	data := bytes.Repeat([]byte("PKG1"), 64)
+	path := filepath.Join(t.TempDir(), "Base.wz")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		limit  int64
+		wantOk bool
+	}{
+		{name: "fits", limit: int64(len(data)), wantOk: true},
+		{name: "too big", limit: int64(len(data)) - 1, wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rs, ok, err := archive.Prefetch(f, tt.limit)
+			if err != nil {
+				t.Fatalf("Prefetch() failed: %v", err)
+			}
+			defer rs.Close()
+
+			if ok != tt.wantOk {
+				t.Errorf("Prefetch() ok = %v, want %v", ok, tt.wantOk)
+			}
+
+			got, err := io.ReadAll(rs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Error("prefetched data does not match file")
+			}
+		})
+	}
+}
+
+// BenchmarkPrefetch compares parsing a generated file with thousands of
+// entries from disk against parsing it after Prefetch.
+func BenchmarkPrefetch(b *testing.B) {
+	root := &wz.Dir{}
+	for i := range 50 {
+		name := fmt.Sprintf("Dir%02d", i)
+		sub := &wz.Dir{Name: name}
+		for j := range 200 {
+			sub.EntriesMetadata = append(sub.EntriesMetadata, wz.DirEntryMetadata{
+				Type: wz.DirEntryTypeFile, Name: fmt.Sprintf("%07d.img", j), FileSize: 16,
+			})
+		}
+		root.EntriesMetadata = append(root.EntriesMetadata, wz.DirEntryMetadata{Type: wz.DirEntryTypeDir, Name: name})
+		root.Subdirs = append(root.Subdirs, sub)
+	}
+
+	file, _, err := wztest.BuildFile(root, wztest.Options{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		b.Fatal(err)
+	}
+	path := filepath.Join(b.TempDir(), "Base.wz")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		b.Fatal(err)
+	}
+
+	// the parse logs at info level, which would dominate the timings
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	cfg := &config.Config{GameRegion: wztest.DefaultRegion, GameVersion: wztest.DefaultVersion}
+
+	for _, prefetch := range []bool{false, true} {
+		name := "file"
+		if prefetch {
+			name = "prefetched"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+
+			for b.Loop() {
+				var rs io.ReadSeekCloser
+				rs, err := os.Open(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if prefetch {
+					if rs, _, err = archive.Prefetch(rs, int64(len(data))); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				if _, err := parser.Parse(rs, cfg); err != nil {
+					b.Fatal(err)
+				}
+				rs.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// scanChunkSize is how much of the input ScanMagic reads at a time.
+const scanChunkSize = 64 << 10
+
+// ErrMagicNotFound is returned by ScanMagic when the magic isn't
+// found within the scan limit.
+var ErrMagicNotFound = errors.New("magic not found")
+
+// ScanMagic searches the first limit bytes of f for magic, for inputs
+// with junk prepended (e.g. some dumps, or concatenated files).
+//
+// It returns a view of f that starts at the magic, so that offsets read
+// from the file line up, along with the offset the magic was found at.
+// Closing the view closes f.
+func ScanMagic(f io.ReadSeekCloser, magic []byte, limit int64) (io.ReadSeekCloser, int64, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("failed to rewind input: %w", err)
+	}
+
+	// keep the tail of the previous chunk so a magic
+	// straddling two chunks is still found
+	overlap := len(magic) - 1
+	buf := make([]byte, scanChunkSize+overlap)
+	r := io.LimitReader(f, limit+int64(overlap))
+
+	var base int64 // input offset of buf[0]
+	kept := 0
+	for {
+		n, err := io.ReadFull(r, buf[kept:])
+		data := buf[:kept+n]
+
+		if i := bytes.Index(data, magic); i >= 0 {
+			offset := base + int64(i)
+			if _, err := f.Seek(offset, io.SeekStart); err != nil {
+				return nil, 0, fmt.Errorf("failed to seek to magic: %w", err)
+			}
+			return &offsetFile{ReadSeekCloser: f, base: offset}, offset, nil
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, 0, fmt.Errorf("%w in the first %d bytes", ErrMagicNotFound, limit)
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan input: %w", err)
+		}
+
+		kept = min(overlap, len(data))
+		copy(buf, data[len(data)-kept:])
+		base += int64(len(data) - kept)
+	}
+}
+
+// offsetFile is a view of a file starting at base.
+type offsetFile struct {
+	io.ReadSeekCloser
+	base int64
+}
+
+func (f *offsetFile) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart {
+		offset += f.base
+	}
+
+	pos, err := f.ReadSeekCloser.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	if pos < f.base {
+		return 0, errors.New("seek before start of file")
+	}
+	return pos - f.base, nil
+}
@@ -0,0 +1,71 @@
+package archive_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ossyrian/mintyparse/internal/archive"
+)
+
+// nopCloser adds a no-op Close to a bytes.Reader
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
+
+func TestScanMagic(t *testing.T) {
+	body := []byte("PKG1 rest of the file")
+
+	tests := []struct {
+		name       string
+		junk       int
+		limit      int64
+		wantErr    error
+		wantOffset int64
+	}{
+		{name: "at start", junk: 0, limit: 16, wantOffset: 0},
+		{name: "after junk", junk: 100, limit: 1024, wantOffset: 100},
+		{name: "straddles chunks", junk: 64<<10 - 2, limit: 1 << 20, wantOffset: 64<<10 - 2},
+		{name: "past limit", junk: 100, limit: 50, wantErr: archive.ErrMagicNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append(bytes.Repeat([]byte{'x'}, tt.junk), body...)
+
+			rs, offset, err := archive.ScanMagic(nopCloser{bytes.NewReader(data)}, []byte("PKG1"), tt.limit)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ScanMagic() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ScanMagic() failed: %v", err)
+			}
+			if offset != tt.wantOffset {
+				t.Errorf("ScanMagic() offset = %d, want %d", offset, tt.wantOffset)
+			}
+
+			// the view starts at the magic
+			if _, err := rs.Seek(5, io.SeekStart); err != nil {
+				t.Fatalf("Seek() failed: %v", err)
+			}
+			got, err := io.ReadAll(rs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(got), "rest") {
+				t.Errorf("read %q after Seek(5), want it to start with %q", got, "rest")
+			}
+
+			if pos, _ := rs.Seek(0, io.SeekCurrent); pos != int64(len(body)) {
+				t.Errorf("position = %d, want %d", pos, len(body))
+			}
+		})
+	}
+}
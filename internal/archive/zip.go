@@ -0,0 +1,89 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// OpenFromZip opens the entry named entryName inside the zip archive at
+// zipPath for random access.
+//
+// The WZ parser needs to seek, which zip entries don't support, so the
+// entry is decompressed up front:
+//   - Entries whose uncompressed size is at most memLimit bytes are read
+//     fully into memory
+//   - Larger entries are extracted to a temporary file, which is removed
+//     when the returned ReadSeekCloser is closed
+func OpenFromZip(zipPath, entryName string, memLimit int64) (io.ReadSeekCloser, error) {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip archive: %w", err)
+	}
+	defer zr.Close()
+
+	var entry *zip.File
+	for _, f := range zr.File {
+		if f.Name == entryName {
+			entry = f
+			break
+		}
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("entry %q not found in %s", entryName, zipPath)
+	}
+
+	rc, err := entry.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip entry %q: %w", entryName, err)
+	}
+	defer rc.Close()
+
+	size := int64(entry.UncompressedSize64)
+	if size <= memLimit {
+		data := make([]byte, size)
+		if _, err := io.ReadFull(rc, data); err != nil {
+			return nil, fmt.Errorf("failed to read zip entry %q: %w", entryName, err)
+		}
+		return memFile{bytes.NewReader(data)}, nil
+	}
+
+	tmp, err := os.CreateTemp("", "mintyparse-*.wz")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	f := &tempFile{tmp}
+
+	if _, err := io.Copy(tmp, rc); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to extract zip entry %q: %w", entryName, err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to rewind temp file: %w", err)
+	}
+
+	return f, nil
+}
+
+// memFile is an in-memory zip entry or prefetched file.
+type memFile struct {
+	*bytes.Reader
+}
+
+func (memFile) Close() error { return nil }
+
+// tempFile is a zip entry extracted to disk; it is deleted on Close.
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	if rmErr := os.Remove(f.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
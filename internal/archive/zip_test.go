@@ -0,0 +1,82 @@
+package archive_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ossyrian/mintyparse/internal/archive"
+)
+
+// buildZip writes a zip archive containing the given entries to a temp dir
+func buildZip(t *testing.T, entries map[string][]byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "client.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, data := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestOpenFromZip(t *testing.T) {
+	data := bytes.Repeat([]byte("PKG1"), 64)
+	zipPath := buildZip(t, map[string][]byte{"Data/Base.wz": data})
+
+	tests := []struct {
+		name     string
+		memLimit int64
+	}{
+		{name: "in memory", memLimit: int64(len(data))},
+		{name: "temp file", memLimit: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := archive.OpenFromZip(zipPath, "Data/Base.wz", tt.memLimit)
+			if err != nil {
+				t.Fatalf("OpenFromZip() failed: %v", err)
+			}
+			defer rs.Close()
+
+			if _, err := rs.Seek(4, io.SeekStart); err != nil {
+				t.Fatalf("Seek() failed: %v", err)
+			}
+			got, err := io.ReadAll(rs)
+			if err != nil {
+				t.Fatalf("ReadAll() failed: %v", err)
+			}
+			if !bytes.Equal(got, data[4:]) {
+				t.Errorf("read %d bytes after seek, want %d", len(got), len(data)-4)
+			}
+		})
+	}
+}
+
+func TestOpenFromZip_MissingEntry(t *testing.T) {
+	zipPath := buildZip(t, map[string][]byte{"Data/Base.wz": []byte("PKG1")})
+
+	if _, err := archive.OpenFromZip(zipPath, "Data/Map.wz", 1024); err == nil {
+		t.Fatal("OpenFromZip() succeeded unexpectedly, wanted error")
+	}
+}
@@ -1,9 +1,18 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // Config holds app configuration
 type Config struct {
 	// GameRegion is the MapleStory region/edition (gms, kms, sea, tms)
-	// Used to determine the encryption IV
+	// Used to determine the encryption IV. A comma-separated list (e.g.
+	// "gms,sea") adds fallback keys for dumps that merge several regions
 	GameRegion string `mapstructure:"game_region"`
 
 	// GameVersion is the MapleStory patch version number (e.g., "263", "230")
@@ -11,11 +20,199 @@ type Config struct {
 	// If not provided, the parser will attempt to bruteforce it
 	GameVersion string `mapstructure:"game_version"`
 
+	// VersionHash is used as the version hash directly when non-zero,
+	// bypassing GameVersion and bruteforcing. For files where no version
+	// string reproduces the right hash
+	VersionHash uint32 `mapstructure:"version_hash"`
+
+	// TryVersions are literal version strings tried before the numeric
+	// ranges when bruteforcing, for test/beta clients (e.g. "1163", "Rb")
+	TryVersions []string `mapstructure:"try_versions"`
+
+	// OffsetConstant overrides wz.OffsetConstant in offset decryption when
+	// non-zero, for modified private server clients that change it
+	OffsetConstant uint32 `mapstructure:"offset_constant"`
+
+	// VersionRanges are "start:end" version number ranges (inclusive) that
+	// replace the built-in ranges when bruteforcing, for obscure clients
+	VersionRanges []string `mapstructure:"version_ranges"`
+
+	// VersionTimeout bounds how long the version bruteforce may run.
+	// Zero means no limit
+	VersionTimeout time.Duration `mapstructure:"version_timeout"`
+
+	// InputFile is the path of the WZ file to parse, or with AllowRemote
+	// an http(s) URL to read it from with range requests
 	InputFile        string `mapstructure:"input"`
 	OutputFile       string `mapstructure:"output"`
 	SpritesOutputDir string `mapstructure:"sprites_dir"`
 
+	// DumpTreeDir is a directory to create an empty directory tree in,
+	// mirroring the WZ directories and images (see writer.DumpTreeDir)
+	DumpTreeDir string `mapstructure:"dump_tree_dir"`
+
+	// KeepEncrypted copies each image's raw encrypted bytes into a
+	// sidecar file next to it in DumpTreeDir
+	KeepEncrypted bool `mapstructure:"keep_encrypted"`
+
+	// MaxPath caps the length of written paths; longer ones are shortened
+	// and listed in a mapping file. 0 means no cap
+	MaxPath int `mapstructure:"max_path"`
+
+	// OutputFormat is the format of OutputFile. Only "json" is supported
+	OutputFormat string `mapstructure:"output_format"`
+
+	// Threads is how many images to parse in parallel, 0 meaning auto
+	// (one per CPU). Only the directory tree is read so far, on a single goroutine,
+	// so it has no effect yet
+	Threads int `mapstructure:"threads"`
+
+	// Stream reads InputFile front to back without seeking ("-" for stdin).
+	// Only the common WZ layout is supported; see parser.StreamReader
+	Stream bool `mapstructure:"stream"`
+
+	// AllowRemote allows InputFile to be an http(s) URL
+	AllowRemote bool `mapstructure:"allow_remote"`
+
+	// RemoteTimeout bounds each HTTP request made to read a URL input,
+	// so a stalled server can't hang the parse
+	RemoteTimeout time.Duration `mapstructure:"remote_timeout"`
+
+	// WzEntry is the path of the WZ file inside a zip archive.
+	// If set, InputFile is treated as a zip archive
+	WzEntry string `mapstructure:"wz_entry"`
+
+	// ZipMemoryLimit is the largest zip entry (in bytes) that will be
+	// read into memory; larger entries are extracted to a temp file
+	ZipMemoryLimit int64 `mapstructure:"zip_memory_limit"`
+
+	// Prefetch reads the whole input into memory before parsing, if it is
+	// at most PrefetchLimit bytes, so parsing is served from RAM
+	Prefetch      bool  `mapstructure:"prefetch"`
+	PrefetchLimit int64 `mapstructure:"prefetch_limit"`
+
+	// ScanMagic searches the first ScanMagicLimit bytes of the input for
+	// the WZ magic and parses from there, for inputs with junk prepended
+	ScanMagic      bool  `mapstructure:"scan_magic"`
+	ScanMagicLimit int64 `mapstructure:"scan_magic_limit"`
+
+	// HeaderLimit caps the header size (the body offset) in bytes, to
+	// reject corrupt files early. 0 means no cap
+	HeaderLimit int64 `mapstructure:"header_limit"`
+
+	// KeyMemoryLimit caps how many bytes of encryption key stream are
+	// kept in memory; longer strings regenerate key bytes as needed.
+	// 0 means no cap (see wz.Key.SetMaxRetained)
+	KeyMemoryLimit int `mapstructure:"key_memory_limit"`
+
+	// AutoRegion retries a failed parse with each other known region,
+	// replacing GameRegion with the first that decrypts the file
+	AutoRegion bool `mapstructure:"auto_region"`
+
+	// StrictRootNames rejects top-level names decrypted with the key
+	// that aren't known WZ root names, in files whose other top-level
+	// names are known (see parser.ErrUnknownRootName)
+	StrictRootNames bool `mapstructure:"strict_root_names"`
+
+	// ContinueOnError collects per-node read errors and keeps going
+	// instead of aborting the parse at the first one
+	ContinueOnError bool `mapstructure:"continue_on_error"`
+
+	// CountOnly prints directory, image, canvas and pixel counts instead
+	// of writing output. Every image is read to count its canvases
+	CountOnly bool `mapstructure:"count_only"`
+
 	DryRun       bool   `mapstructure:"dry_run"`
 	LogLevel     string `mapstructure:"log_level"`
 	LogOutputDir string `mapstructure:"log_output_dir"`
+
+	// LogToState writes log files under the XDG state directory
+	// (see logging.StateLogDir) when LogOutputDir is not set
+	LogToState bool `mapstructure:"log_to_state"`
+}
+
+// Validate checks that required fields are set.
+// Required fields are validated here rather than with cobra's
+// MarkFlagRequired so they can also come from env vars or a config file.
+func (c *Config) Validate() error {
+	if c.InputFile == "" {
+		return errors.New("input is required (--input or MINTYPARSE_INPUT)")
+	}
+	if c.RemoteInput() {
+		if !c.AllowRemote {
+			return fmt.Errorf("input %q is a URL, which requires allow_remote", c.InputFile)
+		}
+		if c.Stream || c.WzEntry != "" {
+			return errors.New("a URL input can't be used with stream or wz_entry")
+		}
+		if c.RemoteTimeout <= 0 {
+			return fmt.Errorf("remote_timeout must be positive for a URL input, got %v", c.RemoteTimeout)
+		}
+	}
+	if c.ScanMagicLimit < 0 {
+		return fmt.Errorf("scan_magic_limit must be at least 0, got %d", c.ScanMagicLimit)
+	}
+	if c.HeaderLimit < 0 {
+		return fmt.Errorf("header_limit must be at least 0 (0 for no cap), got %d", c.HeaderLimit)
+	}
+	if c.KeyMemoryLimit < 0 {
+		return fmt.Errorf("key_memory_limit must be at least 0 (0 for no cap), got %d", c.KeyMemoryLimit)
+	}
+	if c.MaxPath < 0 {
+		return fmt.Errorf("max_path must be at least 0 (0 for no cap), got %d", c.MaxPath)
+	}
+	if c.Threads < 0 {
+		return fmt.Errorf("threads must be at least 0 (0 for auto), got %d", c.Threads)
+	}
+	if c.KeepEncrypted && c.DumpTreeDir == "" {
+		return errors.New("keep_encrypted requires dump_tree_dir to write the sidecar files to")
+	}
+	if c.AutoRegion && c.Stream {
+		return errors.New("auto_region can't be used with stream, as retrying needs to re-read the file")
+	}
+	if c.CountOnly && c.Stream {
+		return errors.New("count_only can't be used with stream, as it needs to seek back to each image")
+	}
+	if c.KeepEncrypted && c.Stream {
+		return errors.New("keep_encrypted can't be used with stream, as it needs to seek back to each image")
+	}
+	switch c.OutputFormat {
+	case "", "json":
+	default:
+		return fmt.Errorf("unsupported output format %q (supported: json)", c.OutputFormat)
+	}
+	for _, r := range c.VersionRanges {
+		if _, _, err := ParseVersionRange(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoteInput reports whether InputFile is an http(s) URL
+func (c *Config) RemoteInput() bool {
+	return strings.HasPrefix(c.InputFile, "http://") || strings.HasPrefix(c.InputFile, "https://")
+}
+
+// ParseVersionRange parses a "start:end" version range, e.g. "1:2000".
+// Both ends are inclusive.
+func ParseVersionRange(s string) (start, end int, err error) {
+	startStr, endStr, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid version range %q: expected start:end", s)
+	}
+
+	start, err = strconv.Atoi(strings.TrimSpace(startStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid version range %q: bad start: %w", s, err)
+	}
+	end, err = strconv.Atoi(strings.TrimSpace(endStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid version range %q: bad end: %w", s, err)
+	}
+	if start < 0 || end < start {
+		return 0, 0, fmt.Errorf("invalid version range %q: end must not be before start", s)
+	}
+
+	return start, end, nil
 }
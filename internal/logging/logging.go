@@ -11,6 +11,10 @@ import (
 	slogmulti "github.com/samber/slog-multi"
 )
 
+// LevelTrace is more verbose than slog.LevelDebug, for raw data that is
+// only useful when reverse-engineering files.
+const LevelTrace = slog.LevelDebug - 4
+
 // Setup configures the global slog logger
 // If logOutputDir is non-empty, logs are written to both stdout and a timestamped file in that directory
 func Setup(levelStr string, logOutputDir string) error {
@@ -48,9 +52,28 @@ func Setup(levelStr string, logOutputDir string) error {
 	return nil
 }
 
+// StateLogDir returns the directory for logs under the XDG state
+// directory: $XDG_STATE_HOME/mintyparse/logs, falling back to
+// ~/.local/state/mintyparse/logs when XDG_STATE_HOME is unset.
+// The directory is created by Setup, not here.
+func StateLogDir() (string, error) {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	// the spec says relative paths are invalid and should be ignored
+	if stateHome == "" || !filepath.IsAbs(stateHome) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find state directory: %w", err)
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateHome, "mintyparse", "logs"), nil
+}
+
 // parseLogLevel converts a string log level to slog.Level
 func parseLogLevel(levelStr string) slog.Level {
 	switch levelStr {
+	case "trace":
+		return LevelTrace
 	case "debug":
 		return slog.LevelDebug
 	case "info":
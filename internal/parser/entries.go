@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+// entryDecoder decodes the fields of directory entries. It holds the
+// state both WzReader and StreamReader need for it, so they decode
+// entries the same way.
+type entryDecoder struct {
+	logger         *slog.Logger
+	header         *wz.Header
+	key            *wz.Key
+	fallbackKeys   []regionKey
+	versionHash    uint32
+	offsetConstant uint32
+}
+
+// readNamedEntry reads the fields of a directory or file entry after its
+// type byte: name, size, checksum and encrypted offset. tell returns the
+// file position of src, which offset decryption depends on.
+func (d *entryDecoder) readNamedEntry(src io.Reader, tell func() (int64, error), entry *wz.DirEntryMetadata) error {
+	// keep the raw name bytes in case it needs keyed decryption
+	var raw bytes.Buffer
+	if err := wz.ReadEncryptedString(io.TeeReader(src, &raw), d.key, &entry.Name); err != nil {
+		return fmt.Errorf("failed to read entry name: %w", err)
+	}
+
+	// list.wz-era clients encrypt some names with the key stream too
+	if !isValidWzName(entry.Name) {
+		var name string
+		if err := wz.ReadKeyedEncryptedString(bytes.NewReader(raw.Bytes()), d.key, &name); err == nil && isValidWzName(name) {
+			d.logger.Debug("decrypted entry name with key stream",
+				"name", name,
+			)
+			entry.Name = name
+			entry.NameKeyed = true
+		}
+	}
+	if !isValidWzName(entry.Name) && len(d.fallbackKeys) > 0 {
+		decryptFallbackName(d.logger, d.fallbackKeys, raw.Bytes(), entry)
+	}
+
+	if err := wz.ReadCompressedInt32(src, &entry.FileSize); err != nil {
+		return fmt.Errorf("failed to read file size for %s: %w", entry.Name, err)
+	}
+	if err := checkFileSize(d.header, entry); err != nil {
+		return err
+	}
+
+	if err := wz.ReadCompressedInt32(src, &entry.Checksum); err != nil {
+		return fmt.Errorf("failed to read checksum for %s: %w", entry.Name, err)
+	}
+
+	offsetPos, err := tell()
+	if err != nil {
+		return fmt.Errorf("failed to get current position: %w", err)
+	}
+	if err := binary.Read(src, binary.LittleEndian, &entry.EncryptedOffset); err != nil {
+		return fmt.Errorf("failed to read offset for %s: %w", entry.Name, err)
+	}
+	entry.OffsetPos = uint32(offsetPos)
+	entry.DataOffset = wz.DecryptOffset(entry.OffsetPos, d.header.BodyOffset, d.versionHash, d.offsetConstant, entry.EncryptedOffset)
+
+	return nil
+}
+
+// readDirEntries reads the count entries of a directory with readEntry,
+// which returns nil for entries to skip, and returns the ones to keep.
+// Shared by WzReader and StreamReader so both filter and log entries
+// the same way.
+func readDirEntries(logger *slog.Logger, count int32, readEntry func(index int) (*wz.DirEntryMetadata, error)) ([]wz.DirEntryMetadata, error) {
+	entries := make([]wz.DirEntryMetadata, 0, count)
+
+	for i := 0; i < int(count); i++ {
+		entry, err := readEntry(i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read entry %d: %w", i, err)
+		}
+		// ignore skipped entries
+		if entry == nil {
+			continue
+		}
+		// an empty name would produce an empty output path, so skip it
+		if entry.Name == "" {
+			logger.Debug("skipping directory entry with empty name",
+				"index", i,
+				"type", entry.Type,
+				"offset", entry.DataOffset,
+			)
+			continue
+		}
+		// zero-size files are kept and treated as empty images
+		if entry.Type == wz.DirEntryTypeFile && entry.FileSize == 0 {
+			logger.Debug("directory entry is an empty image",
+				"index", i,
+				"name", entry.Name,
+			)
+		}
+
+		entries = append(entries, *entry)
+
+		logger.Debug("read directory entry",
+			"index", i,
+			"type", entry.Type,
+			"name", entry.Name,
+			"file_size", entry.FileSize,
+			"checksum", entry.Checksum,
+			"offset", entry.DataOffset,
+		)
+	}
+
+	return entries, nil
+}
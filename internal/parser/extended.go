@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+	"github.com/ossyrian/mintyparse/internal/wztypes"
+)
+
+// Extended property type names, besides propertyContainerName.
+const (
+	extendedTypeCanvas = "Canvas"
+	extendedTypeVector = "Shape2D#Vector2D"
+	extendedTypeConvex = "Shape2D#Convex2D"
+	extendedTypeSound  = "Sound_DX8"
+	extendedTypeUOL    = "UOL"
+)
+
+// readExtendedProperty reads an extended (type 0x09) property: a type
+// name string block followed by data that depends on the type. The
+// caller skips to the end of the property afterwards, so readers only
+// need to read as much as they use.
+//
+// Reference: MapleLib WzImageProperty.ExtractMore
+func (r *WzReader) readExtendedProperty(name string, imageOffset int64) (wztypes.WzProperty, error) {
+	pos, err := r.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	var typeName string
+	if err := wz.ReadOffsetOrInlineString(r.file, r.key, imageOffset, &typeName); err != nil {
+		return nil, fmt.Errorf("failed to read extended property type at offset %d: %w", pos, err)
+	}
+
+	switch typeName {
+	case propertyContainerName:
+		return r.readSubProperty(name, imageOffset)
+	case extendedTypeCanvas:
+		return r.readCanvasProperty(name, imageOffset)
+	case extendedTypeVector:
+		return r.readVectorProperty(name)
+	case extendedTypeConvex:
+		return r.readConvexProperty(name, imageOffset)
+	case extendedTypeSound:
+		return r.readSoundProperty(name)
+	case extendedTypeUOL:
+		return r.readUOLProperty(name, imageOffset)
+	default:
+		return nil, fmt.Errorf("unknown extended property type %q at offset %d", typeName, pos)
+	}
+}
+
+// readSubProperty reads a nested property list.
+func (r *WzReader) readSubProperty(name string, imageOffset int64) (*wztypes.WzSubProperty, error) {
+	props, err := r.readPropertyList(imageOffset)
+	if err != nil {
+		return nil, err
+	}
+	return &wztypes.WzSubProperty{Name: name, Properties: props}, nil
+}
+
+// readCanvasProperty reads a canvas's child properties and the
+// description of its pixel data, skipping the data itself.
+//
+// Format:
+//
+//	[unknown byte][has children byte][property list if has children == 1]
+//	[width][height][format (compressed int32s)][format2 byte][4 unknown bytes]
+//	[data size + 1 (int32)][unknown byte][compressed pixel data]
+func (r *WzReader) readCanvasProperty(name string, imageOffset int64) (*wztypes.WzCanvasProperty, error) {
+	p := &wztypes.WzCanvasProperty{Name: name}
+
+	var flags [2]byte
+	if _, err := io.ReadFull(r.file, flags[:]); err != nil {
+		return nil, fmt.Errorf("failed to read canvas header: %w", err)
+	}
+	if flags[1] == 1 {
+		props, err := r.readPropertyList(imageOffset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read canvas properties: %w", err)
+		}
+		p.Properties = props
+	}
+
+	for _, field := range []*int32{&p.Width, &p.Height, &p.Format} {
+		if err := wz.ReadCompressedInt32(r.file, field); err != nil {
+			return nil, fmt.Errorf("failed to read canvas format: %w", err)
+		}
+	}
+	if err := binary.Read(r.file, binary.LittleEndian, &p.Format2); err != nil {
+		return nil, fmt.Errorf("failed to read canvas format: %w", err)
+	}
+	if _, err := r.file.Seek(4, io.SeekCurrent); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(r.file, binary.LittleEndian, &p.DataSize); err != nil {
+		return nil, fmt.Errorf("failed to read canvas data size: %w", err)
+	}
+	p.DataSize--
+
+	dataOffset, err := r.file.Seek(1, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	p.DataOffset = dataOffset
+
+	return p, nil
+}
+
+// readVectorProperty reads a 2D point: X then Y, as compressed int32s.
+func (r *WzReader) readVectorProperty(name string) (*wztypes.WzVectorProperty, error) {
+	p := &wztypes.WzVectorProperty{Name: name}
+
+	if err := wz.ReadCompressedInt32(r.file, &p.X); err != nil {
+		return nil, fmt.Errorf("failed to read vector X: %w", err)
+	}
+	if err := wz.ReadCompressedInt32(r.file, &p.Y); err != nil {
+		return nil, fmt.Errorf("failed to read vector Y: %w", err)
+	}
+
+	return p, nil
+}
+
+// readConvexProperty reads a polygon: a compressed int32 count, then
+// that many extended properties (usually vectors) without names or
+// sizes. The children are named by their index.
+func (r *WzReader) readConvexProperty(name string, imageOffset int64) (*wztypes.WzConvexProperty, error) {
+	var count int32
+	if err := wz.ReadCompressedInt32(r.file, &count); err != nil {
+		return nil, fmt.Errorf("failed to read convex point count: %w", err)
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("invalid convex point count: %d", count)
+	}
+
+	p := &wztypes.WzConvexProperty{Name: name, Properties: []wztypes.WzProperty{}}
+	for i := range count {
+		child, err := r.readExtendedProperty(strconv.Itoa(int(i)), imageOffset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read convex point %d: %w", i, err)
+		}
+		p.Properties = append(p.Properties, child)
+	}
+
+	return p, nil
+}
+
+// readSoundProperty reads the size and duration of a sound, skipping
+// its header and data.
+//
+// Format:
+//
+//	[unknown byte][data size][duration in ms (compressed int32s)][header][data]
+func (r *WzReader) readSoundProperty(name string) (*wztypes.WzSoundProperty, error) {
+	p := &wztypes.WzSoundProperty{Name: name}
+
+	if _, err := r.file.Seek(1, io.SeekCurrent); err != nil {
+		return nil, err
+	}
+	if err := wz.ReadCompressedInt32(r.file, &p.DataSize); err != nil {
+		return nil, fmt.Errorf("failed to read sound size: %w", err)
+	}
+	if err := wz.ReadCompressedInt32(r.file, &p.Duration); err != nil {
+		return nil, fmt.Errorf("failed to read sound duration: %w", err)
+	}
+
+	return p, nil
+}
+
+// readUOLProperty reads a link: an unknown byte, then the target path
+// as a string block.
+func (r *WzReader) readUOLProperty(name string, imageOffset int64) (*wztypes.WzUOLProperty, error) {
+	p := &wztypes.WzUOLProperty{Name: name}
+
+	if _, err := r.file.Seek(1, io.SeekCurrent); err != nil {
+		return nil, err
+	}
+	if err := wz.ReadOffsetOrInlineString(r.file, r.key, imageOffset, &p.Value); err != nil {
+		return nil, fmt.Errorf("failed to read UOL path: %w", err)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+	"github.com/ossyrian/mintyparse/internal/wztypes"
+)
+
+// Property type bytes, stored after each property's name. Some types
+// have two bytes, from different client versions.
+const (
+	propertyTypeNull     byte = 0x00
+	propertyTypeShort    byte = 0x02
+	propertyTypeInt      byte = 0x03
+	propertyTypeFloat    byte = 0x04
+	propertyTypeDouble   byte = 0x05
+	propertyTypeString   byte = 0x08
+	propertyTypeExtended byte = 0x09
+	propertyTypeShortAlt byte = 0x0B
+	propertyTypeIntAlt   byte = 0x13
+	propertyTypeLong     byte = 0x14
+)
+
+// propertyContainerName is the extended type name of a property list,
+// which every image starts with.
+const propertyContainerName = "Property"
+
+// ReadImage reads the property list of the image entry points at.
+//
+// Images start with a "Property" extended type name (see
+// wz.ReadOffsetOrInlineString), two reserved bytes and then the
+// property list: a compressed int count followed by that many
+// properties, each a name string block and a type byte. String offsets
+// inside the image are relative to its start, entry.DataOffset.
+//
+// Zero-size entries are empty images (see ReadDir) and are returned
+// without reading anything.
+//
+// Reference: MapleLib WzImage.ParseImage
+func (r *WzReader) ReadImage(entry *wz.DirEntryMetadata) (*wztypes.WzImage, error) {
+	offset := int64(entry.DataOffset)
+	if entry.FileSize == 0 {
+		return &wztypes.WzImage{
+			Name:       entry.Name,
+			Offset:     offset,
+			Properties: []wztypes.WzProperty{},
+		}, nil
+	}
+
+	if _, err := r.file.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek to image %q at offset %d: %w", entry.Name, offset, err)
+	}
+
+	// check the leading byte up front, so that a bad offset or a
+	// non-image entry gets a clearer error than a failed string read
+	var lead byte
+	if err := binary.Read(r.file, binary.LittleEndian, &lead); err != nil {
+		return nil, fmt.Errorf("failed to read image %q at offset %d: %w", entry.Name, offset, err)
+	}
+	if lead != wz.StringInlineExtended && lead != wz.StringOffsetExtended {
+		return nil, fmt.Errorf("image %q at offset %d: unknown leading byte 0x%02X, expected a property container (0x%02X or 0x%02X)",
+			entry.Name, offset, lead, wz.StringInlineExtended, wz.StringOffsetExtended)
+	}
+	if _, err := r.file.Seek(-1, io.SeekCurrent); err != nil {
+		return nil, err
+	}
+
+	var typeName string
+	if err := wz.ReadOffsetOrInlineString(r.file, r.key, offset, &typeName); err != nil {
+		return nil, fmt.Errorf("failed to read header of image %q at offset %d: %w", entry.Name, offset, err)
+	}
+	if typeName != propertyContainerName {
+		return nil, fmt.Errorf("image %q at offset %d: header is %q, expected %q",
+			entry.Name, offset, typeName, propertyContainerName)
+	}
+
+	props, err := r.readPropertyList(offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %q at offset %d: %w", entry.Name, offset, err)
+	}
+
+	r.logger.Debug("read image",
+		"name", entry.Name,
+		"offset", offset,
+		"properties", len(props),
+	)
+
+	return &wztypes.WzImage{
+		Name:       entry.Name,
+		Offset:     offset,
+		Properties: props,
+	}, nil
+}
+
+// readPropertyList reads a property list, after its "Property" type
+// name, in the image starting at imageOffset.
+func (r *WzReader) readPropertyList(imageOffset int64) ([]wztypes.WzProperty, error) {
+	// reserved, always zero
+	var reserved uint16
+	if err := binary.Read(r.file, binary.LittleEndian, &reserved); err != nil {
+		return nil, fmt.Errorf("failed to read property list header: %w", err)
+	}
+
+	var count int32
+	if err := wz.ReadCompressedInt32(r.file, &count); err != nil {
+		return nil, fmt.Errorf("failed to read property count: %w", err)
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("invalid property count: %d", count)
+	}
+
+	// not preallocated from count, which a corrupt image could set to
+	// anything
+	props := []wztypes.WzProperty{}
+	for i := range count {
+		var name string
+		if err := wz.ReadOffsetOrInlineString(r.file, r.key, imageOffset, &name); err != nil {
+			return nil, fmt.Errorf("failed to read name of property %d: %w", i, err)
+		}
+
+		var typ byte
+		if err := binary.Read(r.file, binary.LittleEndian, &typ); err != nil {
+			return nil, fmt.Errorf("failed to read type of property %q: %w", name, err)
+		}
+
+		prop, err := r.readProperty(name, typ, imageOffset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read property %q: %w", name, err)
+		}
+		props = append(props, prop)
+	}
+
+	return props, nil
+}
+
+// readProperty reads the value of a property of type byte typ, after
+// its name and type byte.
+//
+// Reference: MapleLib WzImageProperty.ParsePropertyList
+func (r *WzReader) readProperty(name string, typ byte, imageOffset int64) (wztypes.WzProperty, error) {
+	switch typ {
+	case propertyTypeNull:
+		return &wztypes.WzNullProperty{Name: name}, nil
+
+	case propertyTypeShort, propertyTypeShortAlt:
+		p := &wztypes.WzShortProperty{Name: name}
+		if err := binary.Read(r.file, binary.LittleEndian, &p.Value); err != nil {
+			return nil, fmt.Errorf("failed to read short value: %w", err)
+		}
+		return p, nil
+
+	case propertyTypeInt, propertyTypeIntAlt:
+		p := &wztypes.WzIntProperty{Name: name}
+		if err := wz.ReadCompressedInt32(r.file, &p.Value); err != nil {
+			return nil, fmt.Errorf("failed to read int value: %w", err)
+		}
+		return p, nil
+
+	case propertyTypeLong:
+		p := &wztypes.WzLongProperty{Name: name}
+		if err := wz.ReadCompressedInt64(r.file, &p.Value); err != nil {
+			return nil, fmt.Errorf("failed to read long value: %w", err)
+		}
+		return p, nil
+
+	case propertyTypeFloat:
+		p := &wztypes.WzFloatProperty{Name: name}
+		if err := wz.ReadCompressedFloat(r.file, &p.Value); err != nil {
+			return nil, fmt.Errorf("failed to read float value: %w", err)
+		}
+		return p, nil
+
+	case propertyTypeDouble:
+		p := &wztypes.WzDoubleProperty{Name: name}
+		if err := binary.Read(r.file, binary.LittleEndian, &p.Value); err != nil {
+			return nil, fmt.Errorf("failed to read double value: %w", err)
+		}
+		return p, nil
+
+	case propertyTypeString:
+		p := &wztypes.WzStringProperty{Name: name}
+		if err := wz.ReadOffsetOrInlineString(r.file, r.key, imageOffset, &p.Value); err != nil {
+			return nil, fmt.Errorf("failed to read string value: %w", err)
+		}
+		return p, nil
+
+	case propertyTypeExtended:
+		// the size of the extended property, so it can be skipped
+		var size int32
+		if err := binary.Read(r.file, binary.LittleEndian, &size); err != nil {
+			return nil, fmt.Errorf("failed to read extended property size: %w", err)
+		}
+		start, err := r.file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+
+		p, err := r.readExtendedProperty(name, imageOffset)
+		if err != nil {
+			return nil, err
+		}
+
+		// continue after the property even if it wasn't read to the end
+		if _, err := r.file.Seek(start+int64(size), io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to seek past extended property: %w", err)
+		}
+		return p, nil
+
+	default:
+		return nil, fmt.Errorf("unknown property type 0x%02X", typ)
+	}
+}
@@ -0,0 +1,260 @@
+package parser_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+	"github.com/ossyrian/mintyparse/internal/wztypes"
+)
+
+// testImageOffset is where buildImage places the image in the file,
+// so that image-relative string offsets are tested
+const testImageOffset = 8
+
+// buildImage returns a file holding an image at testImageOffset with
+// count properties, whose encoded names and values are in props
+func buildImage(count int8, props []byte) []byte {
+	buf := new(bytes.Buffer)
+	buf.Write(make([]byte, testImageOffset))
+	buf.WriteByte(wz.StringInlineExtended)
+	buf.Write(encryptASCII("Property"))
+	buf.Write([]byte{0x00, 0x00})
+	buf.WriteByte(byte(count))
+	buf.Write(props)
+	return buf.Bytes()
+}
+
+// propertyName encodes name as an inline property name string block
+func propertyName(name string) []byte {
+	return append([]byte{wz.StringInline}, encryptASCII(name)...)
+}
+
+// extendedProperty encodes the type byte, size and value of an extended
+// property of type typeName, whose type-specific data is body
+func extendedProperty(typeName string, body []byte) []byte {
+	return sizedExtendedProperty(append([]byte{wz.StringInlineExtended}, encryptASCII(typeName)...), body)
+}
+
+// extendedPropertyAt is like extendedProperty, with the type name stored
+// elsewhere in the image at offset, relative to the image's start
+func extendedPropertyAt(offset uint32, body []byte) []byte {
+	return sizedExtendedProperty(binary.LittleEndian.AppendUint32([]byte{wz.StringOffsetExtended}, offset), body)
+}
+
+func sizedExtendedProperty(typeName, body []byte) []byte {
+	value := append(typeName, body...)
+
+	out := []byte{0x09}
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(value)))
+	return append(out, value...)
+}
+
+func TestWzReader_ReadImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		zeroSize bool // the entry has FileSize 0 instead of the image's size
+		want     []wztypes.WzProperty
+		wantErr  string
+	}{
+		{
+			name: "empty image",
+			data: buildImage(0, nil),
+			want: []wztypes.WzProperty{},
+		},
+		{
+			// not read at all, so no image header is needed
+			name:     "zero-size entry",
+			data:     make([]byte, testImageOffset),
+			zeroSize: true,
+			want:     []wztypes.WzProperty{},
+		},
+		{
+			name: "null properties",
+			data: buildImage(2, bytes.Join([][]byte{
+				propertyName("info"), {0x00},
+				// name at an offset relative to the image: "Property" at its start
+				{wz.StringOffset, 0x01, 0x00, 0x00, 0x00}, {0x00},
+			}, nil)),
+			want: []wztypes.WzProperty{
+				&wztypes.WzNullProperty{Name: "info"},
+				&wztypes.WzNullProperty{Name: "Property"},
+			},
+		},
+		{
+			name: "property types",
+			data: buildImage(11, bytes.Join([][]byte{
+				propertyName("short"), {0x02, 0xFE, 0xFF},
+				propertyName("short alt"), {0x0B, 0x10, 0x00},
+				propertyName("int"), {0x03, 0x80, 0x40, 0x42, 0x0F, 0x00},
+				propertyName("int alt"), {0x13, 0xFB},
+				propertyName("long"), {0x14, 0x05},
+				propertyName("long full"), {0x14, 0x80}, binary.LittleEndian.AppendUint64(nil, 1<<60),
+				propertyName("float"), {0x04, 0x80, 0x00, 0x00, 0xC0, 0x3F},
+				propertyName("float zero"), {0x04, 0x00},
+				propertyName("double"), {0x05}, binary.LittleEndian.AppendUint64(nil, math.Float64bits(-2.5)),
+				propertyName("string"), {0x08, wz.StringInline}, encryptASCII("hello"),
+				propertyName("sub"), extendedProperty("Property", bytes.Join([][]byte{
+					{0x00, 0x00, 0x01},
+					propertyName("x"), {0x03, 0x07},
+				}, nil)),
+			}, nil)),
+			want: []wztypes.WzProperty{
+				&wztypes.WzShortProperty{Name: "short", Value: -2},
+				&wztypes.WzShortProperty{Name: "short alt", Value: 16},
+				&wztypes.WzIntProperty{Name: "int", Value: 1000000},
+				&wztypes.WzIntProperty{Name: "int alt", Value: -5},
+				&wztypes.WzLongProperty{Name: "long", Value: 5},
+				&wztypes.WzLongProperty{Name: "long full", Value: 1 << 60},
+				&wztypes.WzFloatProperty{Name: "float", Value: 1.5},
+				&wztypes.WzFloatProperty{Name: "float zero", Value: 0},
+				&wztypes.WzDoubleProperty{Name: "double", Value: -2.5},
+				&wztypes.WzStringProperty{Name: "string", Value: "hello"},
+				&wztypes.WzSubProperty{Name: "sub", Properties: []wztypes.WzProperty{
+					&wztypes.WzIntProperty{Name: "x", Value: 7},
+				}},
+			},
+		},
+		{
+			name: "extended types",
+			data: buildImage(3, bytes.Join([][]byte{
+				propertyName("link"), extendedProperty("UOL", append([]byte{0x00, wz.StringInline}, encryptASCII("../0")...)),
+				propertyName("0"), extendedProperty("Canvas", bytes.Join([][]byte{
+					{0x00, 0x01},
+					{0x00, 0x00, 0x01}, propertyName("delay"), {0x03, 0x64},
+					{0x10, 0x20, 0x02, 0x00},
+					{0x00, 0x00, 0x00, 0x00},
+					{0x04, 0x00, 0x00, 0x00}, {0x00},
+					{0xAA, 0xBB, 0xCC},
+				}, nil)),
+				propertyName("sound"), extendedProperty("Sound_DX8", bytes.Join([][]byte{
+					{0x00, 0x80}, binary.LittleEndian.AppendUint32(nil, 48000), {0x7F},
+					make([]byte, 16), // header and data, skipped
+				}, nil)),
+			}, nil)),
+			want: []wztypes.WzProperty{
+				&wztypes.WzUOLProperty{Name: "link", Value: "../0"},
+				&wztypes.WzCanvasProperty{
+					Name: "0",
+					Properties: []wztypes.WzProperty{
+						&wztypes.WzIntProperty{Name: "delay", Value: 100},
+					},
+					Width: 16, Height: 32, Format: 2,
+					// image offset + 79 bytes of headers, properties and canvas fields
+					DataOffset: testImageOffset + 79,
+					DataSize:   3,
+				},
+				&wztypes.WzSoundProperty{Name: "sound", DataSize: 48000, Duration: 127},
+			},
+		},
+		{
+			name: "type name at an offset",
+			data: buildImage(1, bytes.Join([][]byte{
+				// "Property" at the image's start, after its leading byte
+				propertyName("sub"), extendedPropertyAt(1, bytes.Join([][]byte{
+					{0x00, 0x00, 0x01},
+					propertyName("x"), {0x03, 0x07},
+				}, nil)),
+			}, nil)),
+			want: []wztypes.WzProperty{
+				&wztypes.WzSubProperty{Name: "sub", Properties: []wztypes.WzProperty{
+					&wztypes.WzIntProperty{Name: "x", Value: 7},
+				}},
+			},
+		},
+		{
+			name: "vectors",
+			data: buildImage(2, bytes.Join([][]byte{
+				propertyName("origin"), extendedProperty("Shape2D#Vector2D", []byte{0x05, 0xF6}),
+				propertyName("far"), extendedProperty("Shape2D#Vector2D", bytes.Join([][]byte{
+					{0x80}, binary.LittleEndian.AppendUint32(nil, uint32(100000)),
+					{0x80}, binary.LittleEndian.AppendUint32(nil, uint32(0xFFFE7960)), // -100000
+				}, nil)),
+			}, nil)),
+			want: []wztypes.WzProperty{
+				&wztypes.WzVectorProperty{Name: "origin", X: 5, Y: -10},
+				&wztypes.WzVectorProperty{Name: "far", X: 100000, Y: -100000},
+			},
+		},
+		{
+			name: "convex",
+			data: buildImage(1, bytes.Join([][]byte{
+				propertyName("foothold"), extendedProperty("Shape2D#Convex2D", bytes.Join([][]byte{
+					{0x02},
+					{wz.StringInlineExtended}, encryptASCII("Shape2D#Vector2D"), {0x01, 0x02},
+					{wz.StringInlineExtended}, encryptASCII("Shape2D#Vector2D"), {0xFD, 0x04},
+				}, nil)),
+			}, nil)),
+			want: []wztypes.WzProperty{
+				&wztypes.WzConvexProperty{Name: "foothold", Properties: []wztypes.WzProperty{
+					&wztypes.WzVectorProperty{Name: "0", X: 1, Y: 2},
+					&wztypes.WzVectorProperty{Name: "1", X: -3, Y: 4},
+				}},
+			},
+		},
+		{
+			name:    "unknown extended type",
+			data:    buildImage(1, append(propertyName("info"), extendedProperty("Shape2D#Foo", nil)...)),
+			wantErr: `unknown extended property type "Shape2D#Foo" at offset`,
+		},
+		{
+			name:    "unknown property type",
+			data:    buildImage(1, append(propertyName("info"), 0x42)),
+			wantErr: `failed to read property "info": unknown property type 0x42`,
+		},
+		{
+			name:    "unknown leading byte",
+			data:    append(make([]byte, testImageOffset), 0x42),
+			wantErr: `image "Foo.img" at offset 8: unknown leading byte 0x42`,
+		},
+		{
+			name: "wrong header",
+			data: append(append(make([]byte, testImageOffset), wz.StringInlineExtended),
+				encryptASCII("Canvas")...),
+			wantErr: `header is "Canvas", expected "Property"`,
+		},
+		{
+			name:    "truncated value",
+			data:    buildImage(1, append(propertyName("info"), 0x03, 0x80, 0x01)),
+			wantErr: `failed to read property "info": failed to read int value`,
+		},
+		{
+			name:    "truncated",
+			data:    buildImage(1, propertyName("info")),
+			wantErr: `failed to read type of property "info"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newDirReader(t, tt.data)
+			entry := &wz.DirEntryMetadata{Type: wz.DirEntryTypeFile, Name: "Foo.img", DataOffset: testImageOffset}
+			if !tt.zeroSize {
+				entry.FileSize = int32(len(tt.data) - testImageOffset)
+			}
+
+			img, err := r.ReadImage(entry)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadImage() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadImage() failed: %v", err)
+			}
+
+			if img.Name != entry.Name || img.Offset != testImageOffset {
+				t.Errorf("ReadImage() name, offset = %q, %d, want %q, %d", img.Name, img.Offset, entry.Name, testImageOffset)
+			}
+			if !reflect.DeepEqual(img.Properties, tt.want) {
+				t.Errorf("ReadImage() properties = %v, want %v", img.Properties, tt.want)
+			}
+		})
+	}
+}
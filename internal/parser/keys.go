@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/ossyrian/mintyparse/internal/config"
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+// regionKey is the encryption key of a game region.
+type regionKey struct {
+	region string
+	key    *wz.Key
+}
+
+// newRegionKeys initializes the keys for the comma-separated list of game
+// regions in cfg.GameRegion (e.g. "gms,sea"). The first region's key is
+// the primary key; the rest are fallbacks for entry names the primary key
+// can't decrypt, for dumps that merge data from several regions. Each key
+// retains at most cfg.KeyMemoryLimit bytes of key stream.
+func newRegionKeys(logger *slog.Logger, cfg *config.Config) (*wz.Key, []regionKey, error) {
+	names := strings.Split(cfg.GameRegion, ",")
+
+	var keys []regionKey
+	for _, region := range names {
+		region = strings.TrimSpace(region)
+		key, err := newRegionKey(logger, region)
+		if err != nil {
+			return nil, nil, err
+		}
+		key.SetMaxRetained(cfg.KeyMemoryLimit)
+		keys = append(keys, regionKey{region: region, key: key})
+	}
+
+	return keys[0].key, keys[1:], nil
+}
+
+// primaryRegion returns the first region of a comma-separated list.
+func primaryRegion(regions string) string {
+	region, _, _ := strings.Cut(regions, ",")
+	return strings.TrimSpace(region)
+}
+
+// decryptFallbackName decrypts the raw bytes of a keyed entry name (see
+// wz.Key.DecryptKeyedString) with each fallback key in turn, and sets the
+// entry's name from the first that produces a valid name. Names that
+// aren't keyed decrypt the same with every key, so they never need a
+// fallback. Returns false if no key produced a valid name.
+func decryptFallbackName(logger *slog.Logger, fallbacks []regionKey, raw []byte, entry *wz.DirEntryMetadata) bool {
+	for _, fk := range fallbacks {
+		var name string
+		if err := wz.ReadKeyedEncryptedString(bytes.NewReader(raw), fk.key, &name); err != nil || !isValidWzName(name) {
+			continue
+		}
+
+		logger.Debug("decrypted entry name with fallback region key",
+			"name", name,
+			"region", fk.region,
+		)
+		entry.Name = name
+		entry.NameKeyed = true
+		entry.Region = fk.region
+		return true
+	}
+	return false
+}
+
+// withAutoRegion runs fn, a Parse-like function, with cfg. If it fails,
+// or the root directory has names that didn't decrypt, fn is run again
+// from the start of file with each other known region (wz.Regions) in
+// turn. The first region that works replaces cfg.GameRegion, dropping
+// any fallback regions. If none works, the original result is returned.
+func withAutoRegion(file io.ReadSeeker, cfg *config.Config, fn func(io.ReadSeeker, *config.Config) (*Result, error)) (*Result, error) {
+	result, err := fn(file, cfg)
+	if regionWorked(result, err) {
+		return result, err
+	}
+
+	tried := primaryRegion(cfg.GameRegion)
+	for _, region := range wz.Regions {
+		if region == tried {
+			continue
+		}
+
+		slog.Info("retrying with another game region",
+			"file", cfg.InputFile,
+			"region", region,
+			"error", err,
+		)
+		if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil {
+			return result, err
+		}
+
+		retryCfg := *cfg
+		retryCfg.GameRegion = region
+		retryResult, retryErr := fn(file, &retryCfg)
+		if regionWorked(retryResult, retryErr) {
+			slog.Warn("detected game region, the configured one didn't decrypt the file",
+				"file", cfg.InputFile,
+				"configured", cfg.GameRegion,
+				"region", region,
+			)
+			cfg.GameRegion = region
+			return retryResult, retryErr
+		}
+	}
+
+	return result, err
+}
+
+// regionWorked reports whether a parse succeeded, with every name in
+// the root directory decrypted.
+func regionWorked(result *Result, err error) bool {
+	if err != nil || result == nil {
+		return false
+	}
+	for _, entry := range result.Root.EntriesMetadata {
+		if !isValidWzName(entry.Name) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,16 +1,31 @@
 package parser
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/ossyrian/mintyparse/internal/config"
+	"github.com/ossyrian/mintyparse/internal/logging"
 	"github.com/ossyrian/mintyparse/internal/wz"
+	"github.com/ossyrian/mintyparse/internal/wztypes"
 )
 
+// ErrUnsupportedBundle is returned for the single-file data bundles
+// (.ms) shipped by recent clients in place of WZ files, which can't be
+// read yet.
+var ErrUnsupportedBundle = errors.New("unsupported new bundle format")
+
+// ErrVersionNotFound is returned when bruteforcing finds no version that
+// decrypts the file, or gives up after config.VersionTimeout.
+var ErrVersionNotFound = errors.New("no valid version found")
+
 // WzReader reads information from WZ files.
 type WzReader struct {
 	file   io.ReadSeeker
@@ -28,9 +43,38 @@ type WzReader struct {
 	// (e.g., "83", "230", "777") using the VersionHash function.
 	versionHash uint32
 
+	// version is the MapleStory version string versionHash was derived from
+	// (provided by the user or found by bruteforce).
+	version string
+
+	// offsetConstant is the constant used in offset decryption,
+	// wz.OffsetConstant unless overridden by config.OffsetConstant.
+	offsetConstant uint32
+
 	// key is the encryption key stream used for string decryption.
 	// It's generated from the initialization vector (IV) for the game region.
 	key *wz.Key
+
+	// collectErrors records unreadable directories in errs and keeps
+	// reading instead of failing fast (see SetFailFast).
+	collectErrors bool
+	errs          []error
+
+	// fallbackKeys are tried for entry names key can't decrypt, when
+	// config.GameRegion lists more than one region (see newRegionKeys).
+	fallbackKeys []regionKey
+}
+
+// NewWzReader returns a WzReader reading file with the settings in cfg.
+// It fails fast unless cfg.ContinueOnError is set; see SetFailFast.
+func NewWzReader(file io.ReadSeeker, cfg *config.Config, logger *slog.Logger) *WzReader {
+	return &WzReader{
+		file:           file,
+		config:         cfg,
+		logger:         logger,
+		offsetConstant: offsetConstant(logger, cfg),
+		collectErrors:  cfg.ContinueOnError,
+	}
 }
 
 // ReadHeader reads header information from a WZ file.
@@ -44,8 +88,7 @@ func (r *WzReader) ReadHeader() (*wz.Header, error) {
 		return nil, fmt.Errorf("failed to read magic: %w", err)
 	}
 	if h.Magic != wz.Magic {
-		return nil, fmt.Errorf("invalid WZ magic: expected %q, got %q",
-			wz.Magic, h.Magic)
+		return nil, magicError(r.config, h.Magic)
 	}
 
 	if err := binary.Read(r.file, binary.LittleEndian, &h.BodySize); err != nil {
@@ -56,30 +99,26 @@ func (r *WzReader) ReadHeader() (*wz.Header, error) {
 		return nil, fmt.Errorf("failed to read body offset: %w", err)
 	}
 
-	// read everything from current position to BodyOffset
+	// read the start of the rest of the header for the copyright
 	pos, _ := r.file.Seek(0, io.SeekCurrent)
 	remainingHeaderBytes := int(h.BodyOffset) - int(pos)
 	if remainingHeaderBytes < 0 {
 		return nil, fmt.Errorf("invalid BodyOffset: %d", h.BodyOffset)
 	}
+	if err := checkHeaderLimit(r.config, h.BodyOffset); err != nil {
+		return nil, err
+	}
 
-	headerData := make([]byte, remainingHeaderBytes)
+	headerData := make([]byte, min(remainingHeaderBytes, copyrightScanLimit))
 	if _, err := io.ReadFull(r.file, headerData); err != nil {
 		return nil, fmt.Errorf("failed to read header data: %w", err)
 	}
-
-	// extract copyright as ASCII
-	copyrightEnd := 0
-	for i, b := range headerData {
-		if b == 0 || b < 32 || b > 126 {
-			copyrightEnd = i
-			break
-		}
-	}
-	if copyrightEnd == 0 {
-		copyrightEnd = len(headerData)
+	// skip anything past it, e.g. a protected client's padding
+	if _, err := r.file.Seek(int64(h.BodyOffset), io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek to body: %w", err)
 	}
-	h.Copyright = string(headerData[:copyrightEnd])
+
+	h.Copyright, h.CopyrightRaw = extractCopyright(headerData)
 
 	r.logger.Info("header is valid",
 		"magic", h.Magic,
@@ -92,59 +131,169 @@ func (r *WzReader) ReadHeader() (*wz.Header, error) {
 	return h, nil
 }
 
+// copyrightScanLimit is how much of the header after the body offset
+// field is read to find the copyright. Some protected clients pad the
+// header with a large binary blob after it, which is skipped.
+const copyrightScanLimit = 4 << 10
+
+// checkHeaderLimit checks bodyOffset, the size of the header, against
+// cfg.HeaderLimit.
+func checkHeaderLimit(cfg *config.Config, bodyOffset uint32) error {
+	if cfg != nil && cfg.HeaderLimit > 0 && int64(bodyOffset) > cfg.HeaderLimit {
+		return fmt.Errorf("header is %d bytes, over the header limit of %d (raise --header-limit for clients with large headers)",
+			bodyOffset, cfg.HeaderLimit)
+	}
+	return nil
+}
+
+// magicError returns the error for a file that doesn't start with
+// wz.Magic, calling out new-format bundles by their extension since
+// they have no magic of their own.
+func magicError(cfg *config.Config, got [4]byte) error {
+	if cfg != nil {
+		name := cfg.InputFile
+		if cfg.WzEntry != "" {
+			name = cfg.WzEntry
+		}
+		if ext := strings.ToLower(filepath.Ext(name)); ext == ".ms" || ext == ".iwi" {
+			return fmt.Errorf("%w: %s is a %s bundle, not a WZ file (use a pre-2023 client's .wz files)",
+				ErrUnsupportedBundle, filepath.Base(name), ext)
+		}
+	}
+
+	return fmt.Errorf("invalid WZ magic: expected %q, got %q", wz.Magic, got)
+}
+
+// extractCopyright extracts the copyright from the header data following
+// the body offset field. raw holds the bytes up to the first NUL, so a
+// copyright may contain other control characters such as line breaks.
+// Only if there is no NUL in headerData is raw cut at the first control
+// character instead, so binary data after an unterminated copyright is
+// left out. copyright is raw decoded as UTF-8, with invalid sequences
+// replaced.
+func extractCopyright(headerData []byte) (copyright string, raw []byte) {
+	raw = headerData
+	if i := bytes.IndexByte(headerData, 0); i >= 0 {
+		raw = headerData[:i]
+	} else if i := bytes.IndexFunc(headerData, func(r rune) bool { return r < 0x20 }); i >= 0 {
+		raw = headerData[:i]
+	}
+	raw = bytes.Clone(raw)
+
+	return strings.ToValidUTF8(string(raw), "\uFFFD"), raw
+}
+
 // ReadVersionHeader detects and reads the version header if present.
 // Returns the version header value (0 if not present) and any error.
 // The version header is an obfuscated checksum derived from the MapleStory version number.
 func (r *WzReader) ReadVersionHeader() (uint16, error) {
-	var version uint16
-	if err := binary.Read(r.file, binary.LittleEndian, &version); err != nil {
+	if err := r.seekBody(0); err != nil {
+		return 0, err
+	}
+	window := make([]byte, versionHeaderWindow)
+	// a short read is fine for a small file, as long as the header fits
+	n, err := io.ReadFull(r.file, window)
+	if n < 2 {
 		return 0, fmt.Errorf("failed to read version header: %w", err)
 	}
 
+	version, ok := r.detectVersionHeader(window[:n])
+	if !ok {
+		return 0, r.seekBody(0)
+	}
+	return version, r.seekBody(2)
+}
+
+// versionHeaderWindow is how many bytes from the body offset
+// detectVersionHeader looks at: enough for the version header, an
+// entry count and a first entry with a long name.
+const versionHeaderWindow = 1 << 10
+
+// detectVersionHeader decides whether window, the start of the body,
+// begins with a version header, returning its value if so. It is shared
+// by WzReader and StreamReader so both read a file the same way; r only
+// needs its logger, config, header and keys set.
+func (r *WzReader) detectVersionHeader(window []byte) (uint16, bool) {
+	version := binary.LittleEndian.Uint16(window)
+
 	// version headers are single-byte values, so > 255 means no header
 	if version > 0xFF {
 		r.logger.Debug("detected format without version header (value > 255)",
 			"check_value", version)
-		if _, err := r.file.Seek(int64(r.header.BodyOffset), io.SeekStart); err != nil {
-			return 0, fmt.Errorf("failed to seek back to data offset: %w", err)
+		return 0, false
+	}
+
+	// a value this small could be a version header, or the start of
+	// the root directory (a compressed int entry count) in a file
+	// without one, so decrypt the first entry both ways and keep
+	// whichever gives a valid name
+	if r.key != nil {
+		withHeader := r.firstEntryValid(window[2:])
+		withoutHeader := r.firstEntryValid(window)
+		if withHeader != withoutHeader {
+			if withoutHeader {
+				r.logger.Debug("detected format without version header (first entry decrypts)",
+					"check_value", version)
+				return 0, false
+			}
+			r.logger.Debug("detected format with version header (first entry decrypts)",
+				"version_header", version)
+			return version, true
 		}
-		return 0, nil
 	}
 
-	// special case: 0x80 is the compressed int marker
-	// could be a version header OR the start of a compressed int
-	// try reading as compressed int to disambiguate
-	if version == 0x80 {
-		if _, err := r.file.Seek(int64(r.header.BodyOffset), io.SeekStart); err != nil {
-			return 0, fmt.Errorf("failed to seek to data offset: %w", err)
-		}
-
-		var entryCount int32
-		if err := wz.ReadCompressedInt32(r.file, &entryCount); err != nil {
-			return 0, fmt.Errorf("failed to read entry count: %w", err)
-		}
+	// neither or both decrypt; 0x80 is also the compressed int marker,
+	// so it's only a version header if the entry count it would start
+	// is unreasonable
+	if version == 0x80 && len(window) >= 5 {
+		entryCount := int32(binary.LittleEndian.Uint32(window[1:]))
 
 		// if the compressed int decoded to a reasonable entry count, no version header present
 		if entryCount > 0 && entryCount <= 0xFFFF {
 			r.logger.Debug("detected format without version header (compressed int pattern)",
 				"entry_count", entryCount)
-			return 0, nil
-		}
-
-		// looks like a version header after all, seek past it
-		if _, err := r.file.Seek(int64(r.header.BodyOffset)+2, io.SeekStart); err != nil {
-			return 0, fmt.Errorf("failed to seek past version header: %w", err)
+			return 0, false
 		}
-		r.logger.Debug("detected format with version header",
-			"version_header", version)
-		return version, nil
 	}
 
-	// version header present (values 0x00-0x7F, 0x81-0xFF)
+	// version header present (values 0x00-0xFF)
 	r.logger.Debug("detected format with version header",
 		"version_header", version)
+	return version, true
+}
+
+// firstEntryValid reports whether data starts with a directory that has
+// a reasonable entry count and a first entry that decrypts to a valid
+// name. The version hash isn't needed, as names don't depend on it.
+func (r *WzReader) firstEntryValid(data []byte) bool {
+	probe := &WzReader{
+		file:           bytes.NewReader(data),
+		config:         r.config,
+		logger:         r.logger,
+		header:         r.header,
+		offsetConstant: r.offsetConstant,
+		key:            r.key,
+		fallbackKeys:   r.fallbackKeys,
+	}
+
+	var entryCount int32
+	if err := wz.ReadCompressedInt32(probe.file, &entryCount); err != nil {
+		return false
+	}
+	if entryCount <= 0 || entryCount > 0xFFFF {
+		return false
+	}
+
+	entry, err := probe.ReadDirEntryMetadata()
+	return err == nil && entry != nil && isValidWzName(entry.Name)
+}
 
-	return version, nil
+// seekBody seeks to skip bytes past the body offset.
+func (r *WzReader) seekBody(skip int64) error {
+	if _, err := r.file.Seek(int64(r.header.BodyOffset)+skip, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek to directory start: %w", err)
+	}
+	return nil
 }
 
 // determineVersionHash calculates or bruteforces the version hash for offset decryption.
@@ -156,10 +305,20 @@ func (r *WzReader) ReadVersionHeader() (uint16, error) {
 //
 // If userProvidedVersion is empty:
 //   - Bruteforces by trying version ranges until finding one that decrypts correctly
+//
+// A version hash set in the config (config.VersionHash) takes precedence
+// over both: it is used as-is, only warning if it doesn't match the header.
 func (r *WzReader) determineVersionHash(userProvidedVersion string) error {
+	// User provided the hash itself
+	if r.config != nil && r.config.VersionHash != 0 {
+		r.useVersionHash(r.config.VersionHash)
+		return nil
+	}
+
 	// User provided explicit version
 	if userProvidedVersion != "" {
 		r.versionHash = wz.VersionHash(userProvidedVersion)
+		r.version = userProvidedVersion
 
 		// Validate against version header if present
 		if r.versionHeader != 0 {
@@ -170,7 +329,7 @@ func (r *WzReader) determineVersionHash(userProvidedVersion string) error {
 					"expected_header", expectedObfuscated,
 					"actual_header", r.versionHeader)
 
-				if err := r.bruteforceVersion(); err != nil {
+				if err := r.bruteforceVersionWithTimeout(); err != nil {
 					r.logger.Warn("bruteforce failed, using provided version",
 						"error", err)
 				} else {
@@ -189,13 +348,47 @@ func (r *WzReader) determineVersionHash(userProvidedVersion string) error {
 	r.logger.Info("bruteforcing MapleStory version",
 		"version_header", r.versionHeader)
 
-	if err := r.bruteforceVersion(); err != nil {
+	if err := r.bruteforceVersionWithTimeout(); err != nil {
 		return fmt.Errorf("failed to find version: %w (hint: use --game-version flag)", err)
 	}
 
 	return nil
 }
 
+// useVersionHash sets the version hash directly, without a version string.
+// A mismatch with the version header is logged but not fatal, since the
+// hash is an escape hatch for files no version string reproduces.
+func (r *WzReader) useVersionHash(hash uint32) {
+	r.versionHash = hash
+	r.version = ""
+
+	if r.versionHeader != 0 {
+		expectedObfuscated := wz.ObfuscateVersionHash(hash)
+		if expectedObfuscated != r.versionHeader {
+			r.logger.Warn("version hash does not match version header, using it anyway",
+				"version_hash", hash,
+				"expected_header", expectedObfuscated,
+				"actual_header", r.versionHeader)
+		}
+	}
+
+	r.logger.Info("using provided version hash",
+		"version_hash", hash)
+}
+
+// bruteforceVersionWithTimeout runs bruteforceVersion, bounded by
+// config.VersionTimeout if set.
+func (r *WzReader) bruteforceVersionWithTimeout() error {
+	ctx := context.Background()
+	if r.config != nil && r.config.VersionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.config.VersionTimeout)
+		defer cancel()
+	}
+
+	return r.bruteforceVersion(ctx)
+}
+
 // bruteforceVersion finds the MapleStory version by trying candidate versions.
 //
 // For files with version header (old format):
@@ -205,51 +398,122 @@ func (r *WzReader) determineVersionHash(userProvidedVersion string) error {
 // For files without version header (64-bit format):
 //   - Tries versions 770-779 (typical 64-bit encryption versions)
 //
+// Literal version strings from config.TryVersions (e.g. "1163" or "Rb" for
+// test/beta clients) are tried before any numeric range.
+//
 // Validation: A version is considered correct if the first directory entry name
 // decrypts to valid ASCII (alphanumeric + common punctuation).
-func (r *WzReader) bruteforceVersion() error {
+//
+// The context is checked before each candidate; once it is done,
+// ErrVersionNotFound is returned.
+func (r *WzReader) bruteforceVersion(ctx context.Context) error {
 	startPos, err := r.file.Seek(0, io.SeekCurrent)
 	if err != nil {
 		return fmt.Errorf("failed to save position: %w", err)
 	}
 	defer r.file.Seek(startPos, io.SeekStart)
 
+	// User-supplied literal versions (e.g. test/beta clients) go first
+	if r.config != nil {
+		for _, versionStr := range r.config.TryVersions {
+			if err := ctx.Err(); err != nil {
+				return r.bruteforceStopped(err)
+			}
+
+			r.logger.Info("trying literal version",
+				"version", versionStr)
+
+			if r.tryCandidate(versionStr, "literal") {
+				return nil
+			}
+		}
+	}
+
 	// Version ranges to try, ordered by likelihood
 	ranges := r.getVersionRanges()
 
+	effective := make([]string, len(ranges))
+	for i, vRange := range ranges {
+		effective[i] = fmt.Sprintf("%d:%d (%s)", vRange.start, vRange.end, vRange.desc)
+	}
+	r.logger.Info("bruteforcing version ranges",
+		"ranges", effective)
+
 	for _, vRange := range ranges {
 		for v := vRange.start; v <= vRange.end; v++ {
-			versionStr := fmt.Sprintf("%d", v)
-			hash := wz.VersionHash(versionStr)
-
-			// For old format, skip versions that don't match the version header
-			if r.versionHeader != 0 {
-				if wz.ObfuscateVersionHash(hash) != r.versionHeader {
-					continue
-				}
+			if err := ctx.Err(); err != nil {
+				return r.bruteforceStopped(err)
 			}
-
-			// Try parsing with this version hash
-			if r.tryVersion(hash) {
-				r.versionHash = hash
-				r.logger.Info("found matching version",
-					"version", versionStr,
-					"version_hash", hash,
-					"range", vRange.desc)
+			if r.tryCandidate(fmt.Sprintf("%d", v), vRange.desc) {
 				return nil
 			}
 		}
 	}
 
-	return fmt.Errorf("no valid version found (version_header=%d)", r.versionHeader)
+	return fmt.Errorf("%w (version_header=%d)", ErrVersionNotFound, r.versionHeader)
+}
+
+// bruteforceStopped returns the error for a bruteforce cut short by
+// its context (e.g. the version timeout expiring).
+func (r *WzReader) bruteforceStopped(cause error) error {
+	return fmt.Errorf("%w (version_header=%d): %w", ErrVersionNotFound, r.versionHeader, cause)
+}
+
+// tryCandidate tries a single candidate version string during bruteforce,
+// setting r.versionHash if it decrypts the directory correctly.
+// desc describes where the candidate came from, for logging.
+func (r *WzReader) tryCandidate(versionStr string, desc string) bool {
+	hash := wz.VersionHash(versionStr)
+
+	// For old format, skip versions that don't match the version header
+	if r.versionHeader != 0 {
+		if wz.ObfuscateVersionHash(hash) != r.versionHeader {
+			return false
+		}
+	}
+
+	// Try parsing with this version hash
+	if !r.tryVersion(hash) {
+		return false
+	}
+
+	r.versionHash = hash
+	r.version = versionStr
+	r.logger.Info("found matching version",
+		"version", versionStr,
+		"version_hash", hash,
+		"range", desc)
+	return true
 }
 
 // getVersionRanges returns version number ranges to try during bruteforce.
+// Ranges from config.VersionRanges replace the built-in ones entirely.
 func (r *WzReader) getVersionRanges() []struct {
 	start int
 	end   int
 	desc  string
 } {
+	if r.config != nil && len(r.config.VersionRanges) > 0 {
+		var ranges []struct {
+			start int
+			end   int
+			desc  string
+		}
+		for _, s := range r.config.VersionRanges {
+			// already checked by config.Validate
+			start, end, err := config.ParseVersionRange(s)
+			if err != nil {
+				continue
+			}
+			ranges = append(ranges, struct {
+				start int
+				end   int
+				desc  string
+			}{start, end, "user"})
+		}
+		return ranges
+	}
+
 	if r.versionHeader == 0 {
 		// 64-bit format - try standard encryption version range
 		return []struct {
@@ -356,40 +620,63 @@ func (r *WzReader) ReadDir() (*wz.Dir, error) {
 		"entry_count", d.EntryCount,
 	)
 
-	d.EntriesMetadata = make([]wz.DirEntryMetadata, 0, d.EntryCount)
+	entries, err := readDirEntries(r.logger, d.EntryCount, r.readDirEntryMetadata)
+	if err != nil {
+		return nil, err
+	}
+	d.EntriesMetadata = entries
 
-	for i := 0; i < int(d.EntryCount); i++ {
-		entry, err := r.ReadDirEntryMetadata()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read entry %d: %w", i, err)
-		}
-		// ignore skipped entries
-		if entry == nil {
-			continue
-		}
+	logDirStats(r.logger, d)
 
-		d.EntriesMetadata = append(d.EntriesMetadata, *entry)
+	return d, nil
+}
 
-		r.logger.Debug("read directory entry",
-			"index", i,
-			"type", entry.Type,
-			"name", entry.Name,
-			"file_size", entry.FileSize,
-			"checksum", entry.Checksum,
-			"offset", entry.DataOffset,
-		)
+// logDirStats logs a one-line summary of a directory that was just read:
+// its entry count, how many of the entries are subdirectories and
+// images, and the total size the images declare.
+func logDirStats(logger *slog.Logger, d *wz.Dir) {
+	var dirs, images int
+	var imageBytes int64
+	for _, entry := range d.EntriesMetadata {
+		switch entry.Type {
+		case wz.DirEntryTypeDir:
+			dirs++
+		case wz.DirEntryTypeFile:
+			images++
+			imageBytes += int64(entry.FileSize)
+		}
 	}
 
-	r.logger.Info("read directory",
+	logger.Info("read directory",
 		"entry_count", d.EntryCount,
+		"subdirs", dirs,
+		"images", images,
+		"image_bytes", imageBytes,
 	)
+}
 
-	return d, nil
+// ignoredEntrySize is the size of the data after an ignored (type 1)
+// directory entry's type byte.
+const ignoredEntrySize = 10
+
+// logIgnoredEntry logs the otherwise discarded data of an ignored
+// directory entry at trace level, as some tools store metadata there.
+func logIgnoredEntry(logger *slog.Logger, index int, data []byte) {
+	logger.Log(context.Background(), logging.LevelTrace, "ignored directory entry",
+		"index", index,
+		"data", fmt.Sprintf("% x", data),
+	)
 }
 
 // ReadDirEntryMetadata reads the metadata for a single directory entry.
 // Returns nil if the entry should be skipped (type 1).
 func (r *WzReader) ReadDirEntryMetadata() (*wz.DirEntryMetadata, error) {
+	return r.readDirEntryMetadata(0)
+}
+
+// readDirEntryMetadata is ReadDirEntryMetadata for the entry at index
+// in its directory; the index is only used for logging.
+func (r *WzReader) readDirEntryMetadata(index int) (*wz.DirEntryMetadata, error) {
 	entry := &wz.DirEntryMetadata{}
 
 	if err := binary.Read(r.file, binary.LittleEndian, &entry.Type); err != nil {
@@ -398,7 +685,16 @@ func (r *WzReader) ReadDirEntryMetadata() (*wz.DirEntryMetadata, error) {
 
 	switch entry.Type {
 	case wz.DirEntryTypeIgnore:
-		if _, err := r.file.Seek(10, io.SeekCurrent); err != nil {
+		// only read the skipped bytes if someone will see them
+		if r.logger.Enabled(context.Background(), logging.LevelTrace) {
+			var data [ignoredEntrySize]byte
+			if _, err := io.ReadFull(r.file, data[:]); err != nil {
+				return nil, fmt.Errorf("failed to read ignored entry: %w", err)
+			}
+			logIgnoredEntry(r.logger, index, data[:])
+			return nil, nil
+		}
+		if _, err := r.file.Seek(ignoredEntrySize, io.SeekCurrent); err != nil {
 			return nil, fmt.Errorf("failed to skip ignored entry: %w", err)
 		}
 		return nil, nil
@@ -420,23 +716,12 @@ func (r *WzReader) ReadDirEntryMetadata() (*wz.DirEntryMetadata, error) {
 			return nil, fmt.Errorf("failed to seek to referenced entry at offset %d: %w", absoluteOffset, err)
 		}
 
-		return r.ReadDirEntryMetadata()
+		return r.readDirEntryMetadata(index)
 
 	case wz.DirEntryTypeDir, wz.DirEntryTypeFile:
-		if err := wz.ReadEncryptedString(r.file, r.key, &entry.Name); err != nil {
-			return nil, fmt.Errorf("failed to read entry name: %w", err)
-		}
-
-		if err := wz.ReadCompressedInt32(r.file, &entry.FileSize); err != nil {
-			return nil, fmt.Errorf("failed to read file size for %s: %w", entry.Name, err)
-		}
-
-		if err := wz.ReadCompressedInt32(r.file, &entry.Checksum); err != nil {
-			return nil, fmt.Errorf("failed to read checksum for %s: %w", entry.Name, err)
-		}
-
-		if err := wz.ReadEncryptedOffset(r.file, r.header.BodyOffset, r.versionHash, &entry.DataOffset); err != nil {
-			return nil, fmt.Errorf("failed to read offset for %s: %w", entry.Name, err)
+		tell := func() (int64, error) { return r.file.Seek(0, io.SeekCurrent) }
+		if err := r.entryDecoder().readNamedEntry(r.file, tell, entry); err != nil {
+			return nil, err
 		}
 
 		return entry, nil
@@ -446,55 +731,183 @@ func (r *WzReader) ReadDirEntryMetadata() (*wz.DirEntryMetadata, error) {
 	}
 }
 
-func Parse(file *os.File, cfg *config.Config) error {
+// checkFileSize checks that entry's FileSize could fit in the file
+// described by h. A negative or huge size means the entry was misread,
+// usually because an earlier name decrypted to the wrong length; this
+// also makes tryVersion reject the key or version that produced it.
+func checkFileSize(h *wz.Header, entry *wz.DirEntryMetadata) error {
+	fileSize := uint64(h.BodyOffset) + h.BodySize
+	if entry.FileSize < 0 || uint64(entry.FileSize) >= fileSize {
+		return fmt.Errorf("invalid file size for %s: %d (file is %d bytes)", entry.Name, entry.FileSize, fileSize)
+	}
+	return nil
+}
+
+// entryDecoder returns an entryDecoder using r's current key and
+// version hash.
+func (r *WzReader) entryDecoder() *entryDecoder {
+	return &entryDecoder{
+		logger:         r.logger,
+		header:         r.header,
+		key:            r.key,
+		fallbackKeys:   r.fallbackKeys,
+		versionHash:    r.versionHash,
+		offsetConstant: r.offsetConstant,
+	}
+}
+
+// Result holds the structures read from a WZ file.
+type Result struct {
+	Header *wz.Header
+	Root   *wz.Dir
+
+	VersionHeader uint16 // raw version header value (0 if not present)
+	Version       string // MapleStory version used for offset decryption
+	VersionHash   uint32
+
+	reader *WzReader // nil for ParseStream results
+}
+
+// ReadImage reads the image entry points at, with the reader the
+// directory tree was read with. The file passed to Parse must still be
+// open. Results from ParseStream can't read images, as that needs
+// seeking back.
+func (res *Result) ReadImage(entry *wz.DirEntryMetadata) (*wztypes.WzImage, error) {
+	if res.reader == nil {
+		return nil, fmt.Errorf("reading image %s: %w", entry.Name, ErrSeekRequired)
+	}
+	return res.reader.ReadImage(entry)
+}
+
+// Parse reads the WZ file from file using the settings in cfg.
+// With cfg.ContinueOnError, a partial Result may be returned
+// together with a non-nil error. With cfg.AutoRegion, other regions
+// are tried if the configured one fails (see withAutoRegion).
+func Parse(file io.ReadSeeker, cfg *config.Config) (*Result, error) {
+	if cfg.AutoRegion {
+		return withAutoRegion(file, cfg, parse)
+	}
+	return parse(file, cfg)
+}
+
+// Open reads the header of file and finds its version like Parse does,
+// and returns a reader positioned at the root directory. Unlike a
+// Result, the reader can go on to read images with ReadImage.
+// cfg.AutoRegion is not applied.
+func Open(file io.ReadSeeker, cfg *config.Config) (*WzReader, error) {
+	return openReader(file, cfg, slog.With("file", cfg.InputFile))
+}
+
+// parse is Parse for a single region configuration.
+func parse(file io.ReadSeeker, cfg *config.Config) (*Result, error) {
 	logger := slog.With(
 		"file", cfg.InputFile,
 	)
 
 	logger.Info("starting parse")
 
-	reader := &WzReader{
-		file:   file,
-		config: cfg,
-		logger: logger,
+	reader, err := openReader(file, cfg, logger)
+	if err != nil {
+		return nil, err
 	}
 
-	// Read file header
-	_, err := reader.ReadHeader()
+	// Read directory structure
+	root, err := reader.ReadTree()
 	if err != nil {
-		return err
+		logFailedPaths(logger, err)
+		if root == nil {
+			return nil, err
+		}
+	}
+
+	return &Result{
+		Header:        reader.header,
+		Root:          root,
+		VersionHeader: reader.versionHeader,
+		Version:       reader.version,
+		VersionHash:   reader.versionHash,
+		reader:        reader,
+	}, err
+}
+
+// openReader returns a WzReader for file that has read the header and
+// determined the encryption key and version hash, and is positioned at
+// the root directory.
+func openReader(file io.ReadSeeker, cfg *config.Config, logger *slog.Logger) (*WzReader, error) {
+	reader := NewWzReader(file, cfg, logger)
+
+	// Read file header
+	if _, err := reader.ReadHeader(); err != nil {
+		return nil, err
 	}
 
 	// Initialize encryption key from game region IV
-	ivBytes, err := wz.IVForVersion(cfg.GameRegion)
+	var err error
+	reader.key, reader.fallbackKeys, err = newRegionKeys(logger, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to get IV for game region %s: %w", cfg.GameRegion, err)
+		return nil, err
 	}
 
-	var iv [4]byte
-	copy(iv[:], ivBytes)
-	reader.key = wz.NewKey(iv)
-
-	logger.Debug("initialized encryption key",
-		"game_region", cfg.GameRegion,
-		"iv", fmt.Sprintf("%02X %02X %02X %02X", iv[0], iv[1], iv[2], iv[3]))
-
 	// Read version header (0 if not present)
 	reader.versionHeader, err = reader.ReadVersionHeader()
 	if err != nil {
-		return fmt.Errorf("failed to read version header: %w", err)
+		return nil, fmt.Errorf("failed to read version header: %w", err)
 	}
 
 	// Determine version hash for offset decryption
 	if err := reader.determineVersionHash(cfg.GameVersion); err != nil {
-		return err
+		return nil, err
 	}
 
-	// Read directory structure
-	_, err = reader.ReadDir()
+	return reader, nil
+}
+
+// logFailedPaths logs a summary of the nodes that failed to read
+// when continuing on error.
+func logFailedPaths(logger *slog.Logger, err error) {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return
+	}
+
+	var paths []string
+	for _, e := range joined.Unwrap() {
+		var pathErr *PathError
+		if errors.As(e, &pathErr) {
+			paths = append(paths, pathErr.Path)
+		}
+	}
+
+	logger.Warn("parse finished with errors",
+		"failed_count", len(paths),
+		"failed_paths", paths,
+	)
+}
+
+// offsetConstant returns the offset decryption constant to use.
+func offsetConstant(logger *slog.Logger, cfg *config.Config) uint32 {
+	if cfg.OffsetConstant == 0 {
+		return wz.OffsetConstant
+	}
+
+	logger.Info("using custom offset constant",
+		"offset_constant", fmt.Sprintf("0x%08X", cfg.OffsetConstant))
+	return cfg.OffsetConstant
+}
+
+// newRegionKey initializes the encryption key from the IV of a game region.
+func newRegionKey(logger *slog.Logger, region string) (*wz.Key, error) {
+	ivBytes, err := wz.IVForVersion(region)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to get IV for game region %s: %w", region, err)
 	}
 
-	return nil
+	var iv [4]byte
+	copy(iv[:], ivBytes)
+
+	logger.Debug("initialized encryption key",
+		"game_region", region,
+		"iv", fmt.Sprintf("%02X %02X %02X %02X", iv[0], iv[1], iv[2], iv[3]))
+
+	return wz.NewKey(iv), nil
 }
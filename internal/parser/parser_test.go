@@ -2,14 +2,22 @@ package parser_test
 
 import (
 	"bytes"
+	"cmp"
+	"context"
 	"encoding/binary"
+	"errors"
 	"io"
 	"log/slog"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ossyrian/mintyparse/internal/config"
+	"github.com/ossyrian/mintyparse/internal/logging"
 	"github.com/ossyrian/mintyparse/internal/parser"
 	"github.com/ossyrian/mintyparse/internal/wz"
+	"github.com/ossyrian/mintyparse/internal/wztest"
 )
 
 // buildValidHeader creates a valid WZ header byte sequence for testing
@@ -33,6 +41,27 @@ func buildValidHeader(fileSize uint64, copyright string) []byte {
 	return buf.Bytes()
 }
 
+// binaryPadding returns n bytes of binary data starting with a control
+// byte, like the encrypted blob some protected clients put in the
+// header. It has no NUL bytes, so it can't terminate a copyright
+func binaryPadding(n int) []byte {
+	pad := make([]byte, n)
+	for i := range pad {
+		pad[i] = byte(i%255 + 1)
+	}
+	return pad
+}
+
+func TestParse_HeaderLimit(t *testing.T) {
+	file := buildValidHeader(1000, "Package file v1.0\x00"+string(binaryPadding(2048)))
+
+	cfg := &config.Config{GameRegion: "gms", VersionHash: testVersionHash, HeaderLimit: 1024}
+	_, err := parser.Parse(bytes.NewReader(file), cfg)
+	if err == nil || !strings.Contains(err.Error(), "over the header limit of 1024") {
+		t.Errorf("Parse() error = %v, want header limit error", err)
+	}
+}
+
 func TestWzReader_ReadHeader(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -45,10 +74,11 @@ func TestWzReader_ReadHeader(t *testing.T) {
 			name:  "valid header with minimal copyright",
 			input: buildValidHeader(500000, "test"),
 			want: &wz.Header{
-				Magic:      [4]byte{'P', 'K', 'G', '1'},
-				BodySize:   500000,
-				BodyOffset: 20,
-				Copyright:  "test",
+				Magic:        [4]byte{'P', 'K', 'G', '1'},
+				BodySize:     500000,
+				BodyOffset:   20,
+				Copyright:    "test",
+				CopyrightRaw: []byte("test"),
 			},
 			wantErr: false,
 		},
@@ -56,13 +86,36 @@ func TestWzReader_ReadHeader(t *testing.T) {
 			name:  "valid header with empty copyright",
 			input: buildValidHeader(100000, ""),
 			want: &wz.Header{
-				Magic:      [4]byte{'P', 'K', 'G', '1'},
-				BodySize:   100000,
-				BodyOffset: 16,
-				Copyright:  "",
+				Magic:        [4]byte{'P', 'K', 'G', '1'},
+				BodySize:     100000,
+				BodyOffset:   16,
+				Copyright:    "",
+				CopyrightRaw: []byte(""),
 			},
 			wantErr: false,
 		},
+		{
+			name:  "UTF-8 copyright",
+			input: buildValidHeader(1000, "메이플스토리 Copyright\x00"),
+			want: &wz.Header{
+				Magic:        [4]byte{'P', 'K', 'G', '1'},
+				BodySize:     1000,
+				BodyOffset:   uint32(16 + len("메이플스토리 Copyright") + 1),
+				Copyright:    "메이플스토리 Copyright",
+				CopyrightRaw: []byte("메이플스토리 Copyright"),
+			},
+		},
+		{
+			name:  "invalid UTF-8 copyright",
+			input: buildValidHeader(1000, "Wizet \xC5\xB0\xFF\x00"),
+			want: &wz.Header{
+				Magic:        [4]byte{'P', 'K', 'G', '1'},
+				BodySize:     1000,
+				BodyOffset:   16 + 10,
+				Copyright:    "Wizet \u0170\uFFFD",
+				CopyrightRaw: []byte("Wizet \xC5\xB0\xFF"),
+			},
+		},
 		{
 			name:    "invalid magic number",
 			input:   append([]byte{'P', 'K', 'G', '2'}, make([]byte, 100)...),
@@ -124,14 +177,48 @@ func TestWzReader_ReadHeader(t *testing.T) {
 			wantErr: true,
 			errMsg:  "failed to read magic",
 		},
+		{
+			name:  "binary-padded header",
+			input: buildValidHeader(1000, "Package file v1.0\x00"+string(binaryPadding(2048))),
+			want: &wz.Header{
+				Magic:        [4]byte{'P', 'K', 'G', '1'},
+				BodySize:     1000,
+				BodyOffset:   uint32(16 + len("Package file v1.0") + 1 + 2048),
+				Copyright:    "Package file v1.0",
+				CopyrightRaw: []byte("Package file v1.0"),
+			},
+		},
+		{
+			name:  "copyright with line breaks",
+			input: buildValidHeader(1000, "Package file v1.0\r\nCopyright 2002 Wizet, ZZ\x00"),
+			want: &wz.Header{
+				Magic:        [4]byte{'P', 'K', 'G', '1'},
+				BodySize:     1000,
+				BodyOffset:   uint32(16 + len("Package file v1.0\r\nCopyright 2002 Wizet, ZZ") + 1),
+				Copyright:    "Package file v1.0\r\nCopyright 2002 Wizet, ZZ",
+				CopyrightRaw: []byte("Package file v1.0\r\nCopyright 2002 Wizet, ZZ"),
+			},
+		},
+		{
+			name:  "unterminated copyright before binary padding",
+			input: buildValidHeader(1000, "Package file v1.0"+string(binaryPadding(2048))),
+			want: &wz.Header{
+				Magic:        [4]byte{'P', 'K', 'G', '1'},
+				BodySize:     1000,
+				BodyOffset:   uint32(16 + len("Package file v1.0") + 2048),
+				Copyright:    "Package file v1.0",
+				CopyrightRaw: []byte("Package file v1.0"),
+			},
+		},
 		{
 			name:  "large body size",
 			input: buildValidHeader(999999999999, "Large file test"),
 			want: &wz.Header{
-				Magic:      [4]byte{'P', 'K', 'G', '1'},
-				BodySize:   999999999999,
-				BodyOffset: 31, // 16 + len("Large file test") = 16 + 15 = 31
-				Copyright:  "Large file test",
+				Magic:        [4]byte{'P', 'K', 'G', '1'},
+				BodySize:     999999999999,
+				BodyOffset:   31, // 16 + len("Large file test") = 16 + 15 = 31
+				Copyright:    "Large file test",
+				CopyrightRaw: []byte("Large file test"),
 			},
 			wantErr: false,
 		},
@@ -165,6 +252,9 @@ func TestWzReader_ReadHeader(t *testing.T) {
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("ReadHeader() = %+v, want %+v", got, tt.want)
 			}
+			if pos, _ := reader.Seek(0, io.SeekCurrent); pos != int64(tt.want.BodyOffset) {
+				t.Errorf("ReadHeader() left the reader at %d, want BodyOffset %d", pos, tt.want.BodyOffset)
+			}
 		})
 	}
 }
@@ -173,27 +263,729 @@ func TestWzReader_ReadHeader(t *testing.T) {
 func setReaderFile(t *testing.T, r *parser.WzReader, reader io.ReadSeeker) {
 	t.Helper()
 
-	v := reflect.ValueOf(r).Elem()
+	setReaderField(t, r, "file", reader)
+	// Use a no-op logger for tests (discards all output)
+	setReaderField(t, r, "logger", slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// setReaderField uses reflection to set a single unexported field
+// in a reader (a *parser.WzReader or *parser.StreamReader)
+func setReaderField(t *testing.T, r any, name string, value any) {
+	t.Helper()
+
+	field := reflect.ValueOf(r).Elem().FieldByName(name)
+	if !field.IsValid() {
+		t.Fatalf("field '%s' not found in %T", name, r)
+	}
+	field = reflect.NewAt(field.Type(), field.Addr().UnsafePointer()).Elem()
+	field.Set(reflect.ValueOf(value))
+}
+
+// newDirReader returns a WzReader over data that is ready to read
+// directory entries (header, key and version hash set)
+func newDirReader(t *testing.T, data []byte) *parser.WzReader {
+	t.Helper()
+
+	r := &parser.WzReader{}
+	setReaderFile(t, r, bytes.NewReader(data))
+	setReaderField(t, r, "header", &wz.Header{Magic: wz.Magic, BodySize: uint64(len(data))})
+	setReaderField(t, r, "key", wz.NewKey([4]byte{0x4D, 0x23, 0xC7, 0x2B}))
+	setReaderField(t, r, "versionHash", testVersionHash)
+	setReaderField(t, r, "offsetConstant", uint32(wz.OffsetConstant))
+	return r
+}
 
-	// Set file field
-	fileField := v.FieldByName("file")
-	if !fileField.IsValid() {
-		t.Fatal("field 'file' not found in WzReader")
+// encryptASCII encodes name as a WZ encrypted ASCII string
+func encryptASCII(name string) []byte {
+	if name == "" {
+		return []byte{0x00}
 	}
-	fileField = reflect.NewAt(fileField.Type(), fileField.Addr().UnsafePointer()).Elem()
-	fileField.Set(reflect.ValueOf(reader))
 
-	// Set logger field (required for Info/Debug logging in ReadHeader)
-	loggerField := v.FieldByName("logger")
-	if !loggerField.IsValid() {
-		t.Fatal("field 'logger' not found in WzReader")
+	out := []byte{byte(-int8(len(name)))}
+	mask := byte(0xAA)
+	for i := 0; i < len(name); i++ {
+		out = append(out, name[i]^mask)
+		mask++
+	}
+	return out
+}
+
+// encryptKeyedASCII encodes name as a WZ ASCII string that is also
+// encrypted with the key stream (list.wz-era image names)
+func encryptKeyedASCII(key *wz.Key, name string) []byte {
+	out := encryptASCII(name)
+	for i := 0; i < len(name); i++ {
+		out[i+1] ^= key.ByteAt(i)
+	}
+	return out
+}
+
+// testVersionHash is the version hash used by newDirReader
+var testVersionHash = wz.VersionHash("83")
+
+// writeDirEntry appends a single type 3/4 directory entry to buf, with its
+// offset encrypted so that it decrypts to dataOffset (BodyOffset is 0).
+// size and checksum must fit in a single-byte compressed int.
+func writeDirEntry(buf *bytes.Buffer, entryType wz.DirEntryType, name string, size, checksum int8, dataOffset uint32) {
+	writeDirEntryAt(buf, 0, entryType, name, size, checksum, dataOffset)
+}
+
+// writeDirEntryAt is like writeDirEntry for a file with the given BodyOffset,
+// where buf holds the file from its first byte.
+func writeDirEntryAt(buf *bytes.Buffer, bodyOffset uint32, entryType wz.DirEntryType, name string, size, checksum int8, dataOffset uint32) {
+	buf.WriteByte(byte(entryType))
+	buf.Write(encryptASCII(name))
+	buf.WriteByte(byte(size))
+	buf.WriteByte(byte(checksum))
+
+	// DecryptOffset XORs in the encrypted value and then adds BodyOffset*2,
+	// so decrypting zero yields the XOR mask plus BodyOffset*2
+	mask := wz.DecryptOffset(uint32(buf.Len()), bodyOffset, testVersionHash, wz.OffsetConstant, 0) - bodyOffset*2
+	binary.Write(buf, binary.LittleEndian, (dataOffset-bodyOffset*2)^mask)
+}
+
+func TestWzReader_ReadDir_EmptyEntries(t *testing.T) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(3) // entry count
+	writeDirEntry(buf, wz.DirEntryTypeFile, "", 10, 1, 0)
+	writeDirEntry(buf, wz.DirEntryTypeFile, "Empty.img", 0, 0, 0)
+	writeDirEntry(buf, wz.DirEntryTypeDir, "Mob", 20, 2, 0)
+
+	r := newDirReader(t, buf.Bytes())
+	d, err := r.ReadDir()
+	if err != nil {
+		t.Fatalf("ReadDir() failed: %v", err)
+	}
+
+	if d.EntryCount != 3 {
+		t.Errorf("EntryCount = %d, want 3", d.EntryCount)
+	}
+
+	var names []string
+	for _, e := range d.EntriesMetadata {
+		names = append(names, e.Name)
+	}
+	if want := []string{"Empty.img", "Mob"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("entry names = %q, want %q (empty name should be skipped)", names, want)
+	}
+
+	if size := d.EntriesMetadata[0].FileSize; size != 0 {
+		t.Errorf("Empty.img FileSize = %d, want 0", size)
+	}
+}
+
+// versionHeaderBody returns a body that starts with an optional version
+// header, then a root directory with entry count count and one valid
+// entry, Mob.img
+func versionHeaderBody(header, count []byte) []byte {
+	buf := bytes.NewBuffer(append(header, count...))
+	buf.WriteByte(byte(wz.DirEntryTypeFile))
+	buf.Write(encryptASCII("Mob.img"))
+	buf.Write([]byte{10, 1})                          // size, checksum
+	binary.Write(buf, binary.LittleEndian, uint32(0)) // encrypted offset
+	return buf.Bytes()
+}
+
+// versionHeaderTests are shared by the WzReader and StreamReader
+// ReadVersionHeader tests, which must agree
+var versionHeaderTests = []struct {
+	name      string
+	data      []byte
+	want      uint16
+	wantCount int32
+}{
+	{
+		name:      "small header value",
+		data:      versionHeaderBody([]byte{0x05, 0x00}, []byte{0x01}),
+		want:      0x05,
+		wantCount: 1,
+	},
+	{
+		name:      "header value is compressed int marker",
+		data:      versionHeaderBody([]byte{0x80, 0x00}, []byte{0x01}),
+		want:      0x80,
+		wantCount: 1,
+	},
+	{
+		name:      "no header, entry count starts like one",
+		data:      versionHeaderBody(nil, []byte{0x80, 0x00, 0x01, 0x00, 0x00}), // 256 entries
+		want:      0,
+		wantCount: 256,
+	},
+	{
+		name:      "no header",
+		data:      versionHeaderBody(nil, []byte{0x01}),
+		want:      0,
+		wantCount: 1,
+	},
+}
+
+func TestWzReader_ReadVersionHeader(t *testing.T) {
+	for _, tt := range versionHeaderTests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := bytes.NewReader(tt.data)
+			r := newDirReader(t, tt.data)
+			setReaderFile(t, r, file)
+
+			got, err := r.ReadVersionHeader()
+			if err != nil {
+				t.Fatalf("ReadVersionHeader() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadVersionHeader() = %#x, want %#x", got, tt.want)
+			}
+
+			// the reader should be left at the root directory
+			var count int32
+			if err := wz.ReadCompressedInt32(file, &count); err != nil || count != tt.wantCount {
+				t.Fatalf("entry count = %d, %v, want %d", count, err, tt.wantCount)
+			}
+			entry, err := r.ReadDirEntryMetadata()
+			if err != nil || entry.Name != "Mob.img" {
+				t.Errorf("first entry = %+v, %v, want Mob.img", entry, err)
+			}
+		})
+	}
+}
+
+func TestWzReader_ReadDir_IgnoredEntry(t *testing.T) {
+	ignored := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(2) // entry count
+	buf.WriteByte(byte(wz.DirEntryTypeIgnore))
+	buf.Write(ignored)
+	buf.WriteByte(byte(wz.DirEntryTypeFile))
+	buf.Write(encryptASCII("Mob.img"))
+	buf.Write([]byte{10, 1})                          // size, checksum
+	binary.Write(buf, binary.LittleEndian, uint32(0)) // encrypted offset
+
+	tests := []struct {
+		name    string
+		level   slog.Level
+		wantLog bool
+	}{
+		{name: "skipped at debug", level: slog.LevelDebug, wantLog: false},
+		{name: "logged at trace", level: logging.LevelTrace, wantLog: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			r := newDirReader(t, buf.Bytes())
+			setReaderField(t, r, "logger", slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: tt.level})))
+
+			d, err := r.ReadDir()
+			if err != nil {
+				t.Fatalf("ReadDir() failed: %v", err)
+			}
+			if len(d.EntriesMetadata) != 1 || d.EntriesMetadata[0].Name != "Mob.img" {
+				t.Fatalf("entries = %+v, want only Mob.img", d.EntriesMetadata)
+			}
+
+			logged := strings.Contains(logs.String(), `index=0 data="01 02 03 04 05 06 07 08 09 0a"`)
+			if logged != tt.wantLog {
+				t.Errorf("ignored entry logged = %t, want %t; logs:\n%s", logged, tt.wantLog, logs.String())
+			}
+		})
+	}
+}
+
+func TestWzReader_ReadDirEntryMetadata_KeyedName(t *testing.T) {
+	key := wz.NewKey([4]byte{0x4D, 0x23, 0xC7, 0x2B})
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(byte(wz.DirEntryTypeFile))
+	buf.Write(encryptKeyedASCII(key, "Mob.img"))
+	buf.Write([]byte{10, 1})                          // size, checksum
+	binary.Write(buf, binary.LittleEndian, uint32(0)) // encrypted offset
+
+	r := newDirReader(t, buf.Bytes())
+	entry, err := r.ReadDirEntryMetadata()
+	if err != nil {
+		t.Fatalf("ReadDirEntryMetadata() failed: %v", err)
+	}
+
+	if entry.Name != "Mob.img" || !entry.NameKeyed {
+		t.Errorf("entry = {Name: %q, NameKeyed: %t}, want {Name: %q, NameKeyed: true}",
+			entry.Name, entry.NameKeyed, "Mob.img")
+	}
+	if entry.FileSize != 10 || entry.Checksum != 1 {
+		t.Errorf("entry = {FileSize: %d, Checksum: %d}, want {10, 1} (stream desynced)",
+			entry.FileSize, entry.Checksum)
+	}
+}
+
+func TestWzReader_ReadDirEntryMetadata_InvalidFileSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileSize []byte // compressed int
+		errMsg   string
+	}{
+		{name: "negative", fileSize: []byte{0xFB}, errMsg: "invalid file size for Mob.img: -5"},
+		{name: "past end of file", fileSize: []byte{0x80, 0x00, 0x00, 0x10, 0x00}, errMsg: "invalid file size for Mob.img: 1048576"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			buf.WriteByte(byte(wz.DirEntryTypeFile))
+			buf.Write(encryptASCII("Mob.img"))
+			buf.Write(tt.fileSize)
+			buf.WriteByte(1)                                  // checksum
+			binary.Write(buf, binary.LittleEndian, uint32(0)) // encrypted offset
+
+			r := newDirReader(t, buf.Bytes())
+			_, err := r.ReadDirEntryMetadata()
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ReadDirEntryMetadata() error = %v, want containing %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestParse_FallbackRegion(t *testing.T) {
+	sea := wz.NewKey([4]byte{0x2E, 0x23, 0x12, 0x61})
+
+	buf := bytes.NewBuffer(buildValidHeader(1000, "test"))
+	bodyOffset := uint32(buf.Len())
+	buf.WriteByte(2) // entry count
+	writeDirEntryAt(buf, bodyOffset, wz.DirEntryTypeFile, "Foo.img", 10, 1, 0)
+	buf.WriteByte(byte(wz.DirEntryTypeFile))
+	buf.Write(encryptKeyedASCII(sea, "Bar.img"))
+	buf.Write([]byte{10, 1})                          // size, checksum
+	binary.Write(buf, binary.LittleEndian, uint32(0)) // encrypted offset
+
+	cfg := &config.Config{GameRegion: "gms,sea", VersionHash: testVersionHash}
+	result, err := parser.Parse(bytes.NewReader(buf.Bytes()), cfg)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	foo, ok := result.Root.Find("Foo.img")
+	if !ok || foo.Region != "" {
+		t.Errorf("Foo.img = %+v, want it decrypted with the primary key", foo)
+	}
+	bar, ok := result.Root.Find("Bar.img")
+	if !ok || bar.Region != "sea" || !bar.NameKeyed {
+		t.Errorf("Bar.img = %+v, want it decrypted with the sea key", bar)
+	}
+}
+
+func TestParse_StrictRootNames(t *testing.T) {
+	gms := wz.NewKey([4]byte{0x4D, 0x23, 0xC7, 0x2B})
+
+	// buildRoot builds a file whose root has two plain names and one
+	// name encrypted with the key stream
+	buildRoot := func(plain1, plain2, keyed string) []byte {
+		buf := bytes.NewBuffer(buildValidHeader(1000, "test"))
+		bodyOffset := uint32(buf.Len())
+		buf.WriteByte(3) // entry count
+		writeDirEntryAt(buf, bodyOffset, wz.DirEntryTypeFile, plain1, 10, 1, 0)
+		writeDirEntryAt(buf, bodyOffset, wz.DirEntryTypeFile, plain2, 10, 1, 0)
+		buf.WriteByte(byte(wz.DirEntryTypeFile))
+		buf.Write(encryptKeyedASCII(gms, keyed))
+		buf.Write([]byte{10, 1})                          // size, checksum
+		binary.Write(buf, binary.LittleEndian, uint32(0)) // encrypted offset
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name    string
+		file    []byte
+		strict  bool
+		wantErr error
+	}{
+		{name: "known keyed name", file: buildRoot("Mob.img", "Map.img", "Skill.img"), strict: true},
+		{name: "numbered continuation", file: buildRoot("Mob.img", "Mob001", "Mob002"), strict: true},
+		{name: "unknown keyed name", file: buildRoot("Mob.img", "Map.img", "Xq3.img"), strict: true, wantErr: parser.ErrUnknownRootName},
+		{name: "unknown keyed name, not strict", file: buildRoot("Mob.img", "Map.img", "Xq3.img")},
+		{name: "non-standard file", file: buildRoot("Foo.img", "Bar.img", "Xq3.img"), strict: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{GameRegion: "gms", VersionHash: testVersionHash, StrictRootNames: tt.strict}
+			_, err := parser.Parse(bytes.NewReader(tt.file), cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParse_AutoRegion(t *testing.T) {
+	sea := wz.NewKey([4]byte{0x2E, 0x23, 0x12, 0x61})
+
+	buf := bytes.NewBuffer(buildValidHeader(1000, "test"))
+	bodyOffset := uint32(buf.Len())
+	buf.WriteByte(2) // entry count
+	writeDirEntryAt(buf, bodyOffset, wz.DirEntryTypeFile, "Foo.img", 10, 1, 0)
+	buf.WriteByte(byte(wz.DirEntryTypeFile))
+	buf.Write(encryptKeyedASCII(sea, "Bar.img"))
+	buf.Write([]byte{10, 1}) // size, checksum
+	// encrypt a data offset of 0, as Validate checks it (see writeDirEntryAt)
+	mask := wz.DecryptOffset(uint32(buf.Len()), bodyOffset, testVersionHash, wz.OffsetConstant, 0) - bodyOffset*2
+	binary.Write(buf, binary.LittleEndian, (0-bodyOffset*2)^mask)
+
+	for _, validate := range []bool{false, true} {
+		name := "Parse"
+		run := parser.Parse
+		if validate {
+			name, run = "Validate", parser.Validate
+		}
+
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.Config{GameRegion: "gms", VersionHash: testVersionHash, AutoRegion: true}
+			result, err := run(bytes.NewReader(buf.Bytes()), cfg)
+			if err != nil {
+				t.Fatalf("%s() failed: %v", name, err)
+			}
+
+			if cfg.GameRegion != "sea" {
+				t.Errorf("GameRegion = %q, want sea", cfg.GameRegion)
+			}
+			if _, ok := result.Root.Find("Bar.img"); !ok {
+				t.Errorf("root = %+v, want Bar.img decrypted", result.Root.EntriesMetadata)
+			}
+		})
+	}
+
+	t.Run("off", func(t *testing.T) {
+		cfg := &config.Config{GameRegion: "gms", VersionHash: testVersionHash}
+		result, err := parser.Parse(bytes.NewReader(buf.Bytes()), cfg)
+		if err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+		if _, ok := result.Root.Find("Bar.img"); ok || cfg.GameRegion != "gms" {
+			t.Errorf("Parse() decrypted Bar.img with region %q, want no retry", cfg.GameRegion)
+		}
+	})
+}
+
+// buildTree builds a root directory with a readable "Good" subdirectory
+// and a "Bad" subdirectory containing an unknown entry type
+func buildTree() []byte {
+	const goodOffset, badOffset = 0x40, 0x60
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(2) // entry count
+	writeDirEntry(buf, wz.DirEntryTypeDir, "Good", 10, 1, goodOffset)
+	writeDirEntry(buf, wz.DirEntryTypeDir, "Bad", 10, 1, badOffset)
+
+	buf.Write(make([]byte, goodOffset-buf.Len()))
+	buf.WriteByte(1)
+	writeDirEntry(buf, wz.DirEntryTypeFile, "Foo.img", 10, 1, 0)
+
+	buf.Write(make([]byte, badOffset-buf.Len()))
+	buf.WriteByte(1)
+	buf.WriteByte(9) // unknown entry type
+
+	return buf.Bytes()
+}
+
+func TestWzReader_ReadTree(t *testing.T) {
+	t.Run("fails fast by default", func(t *testing.T) {
+		r := newDirReader(t, buildTree())
+		setReaderField(t, r, "config", &config.Config{})
+
+		_, err := r.ReadTree()
+		var pathErr *parser.PathError
+		if !errors.As(err, &pathErr) {
+			t.Fatalf("ReadTree() error = %v, want *PathError", err)
+		}
+		if pathErr.Path != "Bad" {
+			t.Errorf("PathError.Path = %q, want %q", pathErr.Path, "Bad")
+		}
+	})
+
+	t.Run("continues on error", func(t *testing.T) {
+		r := newDirReader(t, buildTree())
+		setReaderField(t, r, "config", &config.Config{})
+		r.SetFailFast(false)
+
+		root, err := r.ReadTree()
+		if err == nil {
+			t.Fatal("ReadTree() succeeded unexpectedly, wanted aggregate error")
+		}
+		if root == nil {
+			t.Fatal("ReadTree() returned nil tree, wanted partial tree")
+		}
+
+		if len(root.Subdirs) != 1 || root.Subdirs[0].Name != "Good" {
+			t.Fatalf("Subdirs = %+v, want only Good", root.Subdirs)
+		}
+		if _, ok := root.Subdirs[0].Find("Foo.img"); !ok {
+			t.Error("Good is missing Foo.img")
+		}
+
+		var pathErr *parser.PathError
+		if !errors.As(err, &pathErr) || pathErr.Path != "Bad" {
+			t.Errorf("ReadTree() error = %v, want PathError for Bad", err)
+		}
+
+		if errs := r.Errors(); len(errs) != 1 || !errors.As(errs[0], &pathErr) || pathErr.Path != "Bad" {
+			t.Errorf("Errors() = %v, want one PathError for Bad", errs)
+		}
+	})
+
+	t.Run("continues on error from config", func(t *testing.T) {
+		cfg := &config.Config{ContinueOnError: true}
+		tree := buildTree()
+		r := parser.NewWzReader(bytes.NewReader(tree), cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		setReaderField(t, r, "header", &wz.Header{Magic: wz.Magic, BodySize: uint64(len(tree))})
+		setReaderField(t, r, "key", wz.NewKey([4]byte{0x4D, 0x23, 0xC7, 0x2B}))
+		setReaderField(t, r, "versionHash", testVersionHash)
+
+		root, err := r.ReadTree()
+		if err == nil || root == nil {
+			t.Fatalf("ReadTree() = %v, %v, want partial tree and aggregate error", root, err)
+		}
+	})
+}
+
+func TestParse_VersionHash(t *testing.T) {
+	cfg := &config.Config{
+		GameRegion:  "gms",
+		GameVersion: "1", // would decrypt offsets wrongly, but is bypassed
+		VersionHash: testVersionHash,
+	}
+
+	result, err := parser.Parse(bytes.NewReader(buildStreamFile(wz.DirEntryTypeFile)), cfg)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	if result.VersionHash != testVersionHash {
+		t.Errorf("VersionHash = %d, want %d", result.VersionHash, testVersionHash)
+	}
+	if result.Version != "" {
+		t.Errorf("Version = %q, want empty", result.Version)
+	}
+	if _, ok := result.Root.Subdir("Mob"); !ok {
+		t.Error("root is missing Mob")
+	}
+}
+
+func TestParse_VersionTimeout(t *testing.T) {
+	// a version header only lets through versions that obfuscate to it,
+	// so candidates other than 83 are rejected
+	buf := bytes.NewBuffer(buildValidHeader(1000, "test"))
+	bodyOffset := uint32(buf.Len())
+	binary.Write(buf, binary.LittleEndian, wz.ObfuscateVersionHash(testVersionHash))
+	buf.WriteByte(1) // entry count
+	writeDirEntryAt(buf, bodyOffset, wz.DirEntryTypeFile, "Mob.img", 10, 1, 0)
+
+	tests := []struct {
+		name        string
+		timeout     time.Duration
+		ranges      []string
+		wantVersion string // empty if the version shouldn't be found
+		wantExpired bool
+	}{
+		{
+			// far more candidates than could be tried before the deadline
+			name:        "expired",
+			timeout:     time.Nanosecond,
+			ranges:      []string{"100:2000000000"},
+			wantExpired: true,
+		},
+		{
+			name:   "no limit, not found",
+			ranges: []string{"100:200"},
+		},
+		{
+			name:        "no limit, found",
+			ranges:      []string{"80:90"},
+			wantVersion: "83",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{GameRegion: "gms", VersionRanges: tt.ranges, VersionTimeout: tt.timeout}
+
+			start := time.Now()
+			result, err := parser.Parse(bytes.NewReader(buf.Bytes()), cfg)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Parse() took %v, want it to stop promptly", elapsed)
+			}
+
+			if tt.wantVersion != "" {
+				if err != nil {
+					t.Fatalf("Parse() failed: %v", err)
+				}
+				if result.Version != tt.wantVersion {
+					t.Errorf("Version = %q, want %q", result.Version, tt.wantVersion)
+				}
+				return
+			}
+
+			if !errors.Is(err, parser.ErrVersionNotFound) {
+				t.Fatalf("Parse() error = %v, want ErrVersionNotFound", err)
+			}
+			if expired := errors.Is(err, context.DeadlineExceeded); expired != tt.wantExpired {
+				t.Errorf("Parse() error = %v, want deadline exceeded = %v", err, tt.wantExpired)
+			}
+		})
+	}
+}
+
+func TestParse_OffsetConstant(t *testing.T) {
+	const custom = 0x12345678
+	const dataOffset = 0x80
+
+	buf := bytes.NewBuffer(buildValidHeader(1000, "test"))
+	bodyOffset := uint32(buf.Len())
+	buf.WriteByte(1) // entry count
+	buf.WriteByte(byte(wz.DirEntryTypeFile))
+	buf.Write(encryptASCII("Foo.img"))
+	buf.Write([]byte{10, 1}) // size, checksum
+	encrypted := wz.EncryptOffset(uint32(buf.Len()), bodyOffset, testVersionHash, custom, dataOffset)
+	binary.Write(buf, binary.LittleEndian, encrypted)
+
+	tests := []struct {
+		name           string
+		offsetConstant uint32
+		wantMatch      bool
+	}{
+		{name: "custom constant", offsetConstant: custom, wantMatch: true},
+		{name: "default constant", offsetConstant: 0, wantMatch: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{GameRegion: "gms", VersionHash: testVersionHash, OffsetConstant: tt.offsetConstant}
+			result, err := parser.Parse(bytes.NewReader(buf.Bytes()), cfg)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			foo, ok := result.Root.Find("Foo.img")
+			if !ok {
+				t.Fatal("root is missing Foo.img")
+			}
+			if match := foo.DataOffset == dataOffset; match != tt.wantMatch {
+				t.Errorf("DataOffset = %#x, want it to match %#x = %v", foo.DataOffset, dataOffset, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		versionHash uint32
+		wantErr     bool
+	}{
+		{name: "correct version", versionHash: testVersionHash},
+		{name: "wrong version", versionHash: wz.VersionHash("84"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{GameRegion: "gms", VersionHash: tt.versionHash}
+
+			result, err := parser.Validate(bytes.NewReader(buildStreamFile(wz.DirEntryTypeFile)), cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Validate() succeeded unexpectedly, wanted error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() failed: %v", err)
+			}
+
+			if len(result.Root.EntriesMetadata) != 2 {
+				t.Errorf("checked %d root entries, want 2", len(result.Root.EntriesMetadata))
+			}
+			if len(result.Root.Subdirs) != 0 {
+				t.Errorf("Validate() read %d subdirectories, want none", len(result.Root.Subdirs))
+			}
+		})
+	}
+}
+
+func TestParse_UnsupportedBundle(t *testing.T) {
+	cfg := &config.Config{InputFile: "Data/Base.ms", GameRegion: "gms"}
+
+	_, err := parser.Parse(bytes.NewReader(make([]byte, 64)), cfg)
+	if !errors.Is(err, parser.ErrUnsupportedBundle) {
+		t.Errorf("Parse() error = %v, want ErrUnsupportedBundle", err)
 	}
-	loggerField = reflect.NewAt(loggerField.Type(), loggerField.Addr().UnsafePointer()).Elem()
-	// Use a no-op logger for tests (discards all output)
-	loggerField.Set(reflect.ValueOf(slog.New(slog.NewTextHandler(io.Discard, nil))))
 }
 
 // contains checks if a string contains a substring
 func contains(s, substr string) bool {
 	return bytes.Contains([]byte(s), []byte(substr))
 }
+
+func TestParse_RoundTrip(t *testing.T) {
+	root := &wz.Dir{
+		EntriesMetadata: []wz.DirEntryMetadata{
+			{Type: wz.DirEntryTypeDir, Name: "Mob"},
+			{Type: wz.DirEntryTypeDir, Name: "Empty"},
+			{Type: wz.DirEntryTypeFile, Name: "Foo.img", FileSize: 8, Checksum: 3},
+		},
+		Subdirs: []*wz.Dir{{
+			Name: "Mob",
+			EntriesMetadata: []wz.DirEntryMetadata{
+				{Type: wz.DirEntryTypeDir, Name: "Boss"},
+				{Type: wz.DirEntryTypeFile, Name: "0100100.img", Checksum: -1},
+			},
+			Subdirs: []*wz.Dir{{
+				Name: "Boss",
+				EntriesMetadata: []wz.DirEntryMetadata{
+					{Type: wz.DirEntryTypeFile, Name: "8800000.img", FileSize: 300, Checksum: 200},
+				},
+			}},
+		}},
+	}
+	images := map[string][]byte{"Mob/0100100.img": []byte("image data")}
+
+	tests := []struct {
+		name string
+		opts wztest.Options
+	}{
+		{name: "with version header", opts: wztest.Options{Images: images}},
+		{name: "without version header", opts: wztest.Options{Images: images, NoVersionHeader: true}},
+		{name: "other region", opts: wztest.Options{Images: images, Region: "sea", Version: "188"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, want, err := wztest.BuildFile(root, tt.opts)
+			if err != nil {
+				t.Fatalf("BuildFile() failed: %v", err)
+			}
+
+			cfg := &config.Config{
+				GameRegion:  cmp.Or(tt.opts.Region, wztest.DefaultRegion),
+				GameVersion: cmp.Or(tt.opts.Version, wztest.DefaultVersion),
+			}
+			got, err := parser.Parse(file, cfg)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got.Root, want) {
+				t.Errorf("Parse() tree = %+v, want %+v", got.Root, want)
+			}
+
+			file.Seek(0, io.SeekStart)
+			streamed, err := parser.ParseStream(streamOnly{file}, cfg)
+			if err != nil {
+				t.Fatalf("ParseStream() failed: %v", err)
+			}
+			if !reflect.DeepEqual(streamed.Root, want) {
+				t.Errorf("ParseStream() tree = %+v, want %+v", streamed.Root, want)
+			}
+
+			img, _ := got.Root.Subdirs[0].Find("0100100.img")
+			data := make([]byte, img.FileSize)
+			if _, err := file.ReadAt(data, int64(img.DataOffset)); err != nil || string(data) != "image data" {
+				t.Errorf("image data = %q, %v, want %q", data, err, "image data")
+			}
+		})
+	}
+}
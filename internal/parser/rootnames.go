@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+// ErrUnknownRootName is returned with config.StrictRootNames when a
+// top-level name that needed the key to decrypt isn't a known WZ root
+// name (see wz.IsKnownRoot), which usually means the wrong region was
+// used.
+var ErrUnknownRootName = errors.New("top-level name is not a known WZ root name")
+
+// checkRootNames is a stricter check than isValidWzName for the names
+// of the root directory's entries. Plain names decrypt the same with
+// every region's key, so only keyed names can come out wrong; a wrong
+// region may still produce short alphanumeric garbage that passes
+// isValidWzName. If most plain names are known root names, the file
+// looks like a standard data file, and every keyed name must be known
+// too. Files that don't look standard aren't checked.
+func checkRootNames(logger *slog.Logger, root *wz.Dir) error {
+	var plain, known int
+	for _, entry := range root.EntriesMetadata {
+		if !entry.NameKeyed {
+			plain++
+			if wz.IsKnownRoot(entry.Name) {
+				known++
+			}
+		}
+	}
+	if plain == 0 || known*2 < plain {
+		logger.Debug("not checking top-level names, file doesn't look like a standard data file",
+			"plain_names", plain,
+			"known_names", known)
+		return nil
+	}
+
+	for _, entry := range root.EntriesMetadata {
+		if entry.NameKeyed && !wz.IsKnownRoot(entry.Name) {
+			return fmt.Errorf("%w: %q (the game region may be wrong)", ErrUnknownRootName, entry.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+
+	"github.com/ossyrian/mintyparse/internal/config"
+	"github.com/ossyrian/mintyparse/internal/logging"
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+// ErrSeekRequired is returned by StreamReader when the file uses a
+// construct that can only be read by seeking backwards.
+var ErrSeekRequired = errors.New("construct requires seeking, which is not supported when streaming")
+
+// StreamReader reads the header and directory tree of a WZ file from a
+// forward-only io.Reader, e.g. a file that is still being downloaded.
+//
+// It is best-effort and only handles the common layout:
+//   - The MapleStory version must be known up front (config.GameVersion),
+//     since bruteforcing it requires re-reading the first entry
+//   - Reference entries (type 2) are not supported, as they point back
+//     into data that has already been consumed
+//   - Subdirectories must be stored after their parent directory; they
+//     are read in offset order, skipping forward over any data in between
+//
+// Keyed entry names (see Key.DecryptKeyedString) are still detected.
+// Any construct that needs a backwards seek fails with ErrSeekRequired.
+type StreamReader struct {
+	src    *streamSource
+	config *config.Config
+	logger *slog.Logger
+	header *wz.Header
+
+	versionHeader  uint16
+	versionHash    uint32
+	offsetConstant uint32
+	key            *wz.Key
+	fallbackKeys   []regionKey
+}
+
+// streamSource is a buffered forward-only reader that tracks its position.
+type streamSource struct {
+	br  *bufio.Reader
+	pos int64
+}
+
+func (s *streamSource) Read(p []byte) (int, error) {
+	n, err := s.br.Read(p)
+	s.pos += int64(n)
+	return n, err
+}
+
+// skipTo discards data up to the absolute offset off.
+func (s *streamSource) skipTo(off int64) error {
+	if off < s.pos {
+		return fmt.Errorf("offset %d is behind current position %d: %w", off, s.pos, ErrSeekRequired)
+	}
+	n, err := s.br.Discard(int(off - s.pos))
+	s.pos += int64(n)
+	return err
+}
+
+// NewStreamReader returns a StreamReader reading from r.
+func NewStreamReader(r io.Reader, cfg *config.Config, logger *slog.Logger) *StreamReader {
+	return &StreamReader{
+		src:            &streamSource{br: bufio.NewReader(r)},
+		config:         cfg,
+		logger:         logger,
+		offsetConstant: offsetConstant(logger, cfg),
+	}
+}
+
+// ReadHeader reads header information from the stream.
+// See WzReader.ReadHeader.
+func (s *StreamReader) ReadHeader() (*wz.Header, error) {
+	h := &wz.Header{}
+
+	if _, err := io.ReadFull(s.src, h.Magic[:]); err != nil {
+		return nil, fmt.Errorf("failed to read magic: %w", err)
+	}
+	if h.Magic != wz.Magic {
+		return nil, magicError(s.config, h.Magic)
+	}
+
+	if err := binary.Read(s.src, binary.LittleEndian, &h.BodySize); err != nil {
+		return nil, fmt.Errorf("failed to read body size: %w", err)
+	}
+
+	if err := binary.Read(s.src, binary.LittleEndian, &h.BodyOffset); err != nil {
+		return nil, fmt.Errorf("failed to read body offset: %w", err)
+	}
+
+	remainingHeaderBytes := int(h.BodyOffset) - int(s.src.pos)
+	if remainingHeaderBytes < 0 {
+		return nil, fmt.Errorf("invalid BodyOffset: %d", h.BodyOffset)
+	}
+	if err := checkHeaderLimit(s.config, h.BodyOffset); err != nil {
+		return nil, err
+	}
+
+	headerData := make([]byte, min(remainingHeaderBytes, copyrightScanLimit))
+	if _, err := io.ReadFull(s.src, headerData); err != nil {
+		return nil, fmt.Errorf("failed to read header data: %w", err)
+	}
+	if err := s.src.skipTo(int64(h.BodyOffset)); err != nil {
+		return nil, fmt.Errorf("failed to skip rest of header: %w", err)
+	}
+	h.Copyright, h.CopyrightRaw = extractCopyright(headerData)
+
+	s.logger.Info("header is valid",
+		"magic", h.Magic,
+		"body_size", h.BodySize,
+		"body_offset", h.BodyOffset,
+		"copyright", h.Copyright,
+	)
+
+	s.header = h
+	return h, nil
+}
+
+// ReadVersionHeader detects and reads the version header if present.
+// See WzReader.ReadVersionHeader; the bytes are peeked rather than
+// read and seeked back over.
+func (s *StreamReader) ReadVersionHeader() (uint16, error) {
+	// a short peek is fine for a small file, as long as the header fits
+	window, err := s.src.br.Peek(versionHeaderWindow)
+	if len(window) < 2 {
+		return 0, fmt.Errorf("failed to read version header: %w", err)
+	}
+
+	probe := &WzReader{
+		config:         s.config,
+		logger:         s.logger,
+		header:         s.header,
+		offsetConstant: s.offsetConstant,
+		key:            s.key,
+		fallbackKeys:   s.fallbackKeys,
+	}
+	version, ok := probe.detectVersionHeader(window)
+	if !ok {
+		return 0, nil
+	}
+
+	if _, err := s.src.br.Discard(2); err != nil {
+		return 0, fmt.Errorf("failed to skip version header: %w", err)
+	}
+	s.src.pos += 2
+	return version, nil
+}
+
+// ReadTree reads the root directory and all subdirectories below it.
+// Subdirectories are read in file order; see StreamReader for limitations.
+func (s *StreamReader) ReadTree() (*wz.Dir, error) {
+	root, err := s.readDir()
+	if err != nil {
+		return nil, err
+	}
+	if s.config.StrictRootNames {
+		if err := checkRootNames(s.logger, root); err != nil {
+			return nil, err
+		}
+	}
+
+	type pendingDir struct {
+		parent *wz.Dir
+		entry  wz.DirEntryMetadata
+	}
+
+	var pending []pendingDir
+	queue := func(d *wz.Dir) {
+		for _, entry := range d.EntriesMetadata {
+			if entry.Type == wz.DirEntryTypeDir {
+				pending = append(pending, pendingDir{parent: d, entry: entry})
+			}
+		}
+		sort.SliceStable(pending, func(i, j int) bool {
+			return pending[i].entry.DataOffset < pending[j].entry.DataOffset
+		})
+	}
+	queue(root)
+
+	for len(pending) > 0 {
+		next := pending[0]
+		pending = pending[1:]
+
+		if err := s.src.skipTo(int64(next.entry.DataOffset)); err != nil {
+			return nil, fmt.Errorf("failed to reach directory %s: %w", next.entry.Name, err)
+		}
+
+		sub, err := s.readDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory %s: %w", next.entry.Name, err)
+		}
+		sub.Name = next.entry.Name
+
+		next.parent.Subdirs = append(next.parent.Subdirs, sub)
+		queue(sub)
+	}
+
+	return root, nil
+}
+
+// readDir reads a directory at the current position.
+func (s *StreamReader) readDir() (*wz.Dir, error) {
+	d := &wz.Dir{}
+	if err := wz.ReadCompressedInt32(s.src, &d.EntryCount); err != nil {
+		return nil, err
+	}
+
+	entries, err := readDirEntries(s.logger, d.EntryCount, s.readDirEntryMetadata)
+	if err != nil {
+		return nil, err
+	}
+	d.EntriesMetadata = entries
+
+	logDirStats(s.logger, d)
+
+	return d, nil
+}
+
+// readDirEntryMetadata reads the metadata for the entry at index in
+// its directory. Returns nil if the entry should be skipped (type 1).
+func (s *StreamReader) readDirEntryMetadata(index int) (*wz.DirEntryMetadata, error) {
+	entry := &wz.DirEntryMetadata{}
+
+	if err := binary.Read(s.src, binary.LittleEndian, &entry.Type); err != nil {
+		return nil, fmt.Errorf("failed to read entry type: %w", err)
+	}
+
+	switch entry.Type {
+	case wz.DirEntryTypeIgnore:
+		if s.logger.Enabled(context.Background(), logging.LevelTrace) {
+			var data [ignoredEntrySize]byte
+			if _, err := io.ReadFull(s.src, data[:]); err != nil {
+				return nil, fmt.Errorf("failed to read ignored entry: %w", err)
+			}
+			logIgnoredEntry(s.logger, index, data[:])
+			return nil, nil
+		}
+		if err := s.src.skipTo(s.src.pos + ignoredEntrySize); err != nil {
+			return nil, fmt.Errorf("failed to skip ignored entry: %w", err)
+		}
+		return nil, nil
+
+	case wz.DirEntryTypeReference:
+		return nil, fmt.Errorf("reference entry: %w", ErrSeekRequired)
+
+	case wz.DirEntryTypeDir, wz.DirEntryTypeFile:
+		d := &entryDecoder{
+			logger:         s.logger,
+			header:         s.header,
+			key:            s.key,
+			fallbackKeys:   s.fallbackKeys,
+			versionHash:    s.versionHash,
+			offsetConstant: s.offsetConstant,
+		}
+		tell := func() (int64, error) { return s.src.pos, nil }
+		if err := d.readNamedEntry(s.src, tell, entry); err != nil {
+			return nil, err
+		}
+
+		return entry, nil
+
+	default:
+		return nil, fmt.Errorf("unknown directory entry type: %d", entry.Type)
+	}
+}
+
+// ParseStream parses a WZ file from a forward-only reader.
+// See StreamReader for the limitations compared to Parse.
+func ParseStream(r io.Reader, cfg *config.Config) (*Result, error) {
+	logger := slog.With(
+		"file", cfg.InputFile,
+	)
+
+	logger.Info("starting streaming parse")
+
+	if cfg.GameVersion == "" && cfg.VersionHash == 0 {
+		return nil, errors.New("streaming parse requires the MapleStory version (use --game-version or --version-hash flag)")
+	}
+
+	reader := NewStreamReader(r, cfg, logger)
+
+	header, err := reader.ReadHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	reader.key, reader.fallbackKeys, err = newRegionKeys(logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	reader.versionHeader, err = reader.ReadVersionHeader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version header: %w", err)
+	}
+
+	if cfg.VersionHash != 0 {
+		reader.versionHash = cfg.VersionHash
+		if reader.versionHeader != 0 && wz.ObfuscateVersionHash(reader.versionHash) != reader.versionHeader {
+			logger.Warn("version hash does not match version header, using it anyway",
+				"version_hash", reader.versionHash,
+				"actual_header", reader.versionHeader)
+		}
+	} else {
+		reader.versionHash = wz.VersionHash(cfg.GameVersion)
+		if reader.versionHeader != 0 && wz.ObfuscateVersionHash(reader.versionHash) != reader.versionHeader {
+			return nil, fmt.Errorf("version %s does not match version header %d", cfg.GameVersion, reader.versionHeader)
+		}
+	}
+
+	// the version string is unknown when the hash was given directly
+	version := cfg.GameVersion
+	if cfg.VersionHash != 0 {
+		version = ""
+	}
+
+	logger.Info("using MapleStory version",
+		"version", version,
+		"version_hash", reader.versionHash)
+
+	root, err := reader.ReadTree()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Header:        header,
+		Root:          root,
+		VersionHeader: reader.versionHeader,
+		Version:       version,
+		VersionHash:   reader.versionHash,
+	}, nil
+}
@@ -0,0 +1,152 @@
+package parser_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/ossyrian/mintyparse/internal/config"
+	"github.com/ossyrian/mintyparse/internal/parser"
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+// buildStreamFile builds a complete WZ file (no version header) whose root
+// directory has a "Mob" subdirectory stored after it
+func buildStreamFile(entryType wz.DirEntryType) []byte {
+	buf := bytes.NewBuffer(buildValidHeader(1000, "test"))
+	bodyOffset := uint32(buf.Len())
+	const mobOffset = 0x40
+
+	buf.WriteByte(2) // entry count
+	writeDirEntryAt(buf, bodyOffset, wz.DirEntryTypeDir, "Mob", 10, 1, mobOffset)
+	writeDirEntryAt(buf, bodyOffset, entryType, "Skill", 10, 1, 0)
+
+	buf.Write(make([]byte, mobOffset-buf.Len()))
+	buf.WriteByte(1)
+	writeDirEntryAt(buf, bodyOffset, wz.DirEntryTypeFile, "0100100.img", 10, 1, 0)
+
+	return buf.Bytes()
+}
+
+// streamOnly hides any Seek method of the wrapped reader
+type streamOnly struct {
+	io.Reader
+}
+
+func readStreamTree(t *testing.T, data []byte) (*wz.Dir, error) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	r := parser.NewStreamReader(streamOnly{bytes.NewReader(data)}, &config.Config{}, logger)
+	setReaderField(t, r, "key", wz.NewKey([4]byte{0x4D, 0x23, 0xC7, 0x2B}))
+	setReaderField(t, r, "versionHash", testVersionHash)
+
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatalf("ReadHeader() failed: %v", err)
+	}
+	if v, err := r.ReadVersionHeader(); err != nil || v != 0 {
+		t.Fatalf("ReadVersionHeader() = %d, %v, want 0, nil", v, err)
+	}
+
+	return r.ReadTree()
+}
+
+func TestStreamReader_ReadTree(t *testing.T) {
+	root, err := readStreamTree(t, buildStreamFile(wz.DirEntryTypeFile))
+	if err != nil {
+		t.Fatalf("ReadTree() failed: %v", err)
+	}
+
+	if len(root.EntriesMetadata) != 2 {
+		t.Fatalf("root has %d entries, want 2", len(root.EntriesMetadata))
+	}
+	if len(root.Subdirs) != 1 || root.Subdirs[0].Name != "Mob" {
+		t.Fatalf("Subdirs = %+v, want only Mob", root.Subdirs)
+	}
+	if _, ok := root.Subdirs[0].Find("0100100.img"); !ok {
+		t.Error("Mob is missing 0100100.img")
+	}
+}
+
+func TestStreamReader_ReadTree_SeekRequired(t *testing.T) {
+	// "Skill" becomes a directory at offset 0, which is behind the stream
+	_, err := readStreamTree(t, buildStreamFile(wz.DirEntryTypeDir))
+	if !errors.Is(err, parser.ErrSeekRequired) {
+		t.Errorf("ReadTree() error = %v, want ErrSeekRequired", err)
+	}
+}
+
+func TestStreamReader_ReadTree_EmptyEntries(t *testing.T) {
+	buf := bytes.NewBuffer(buildValidHeader(1000, "test"))
+	bodyOffset := uint32(buf.Len())
+	buf.WriteByte(2) // entry count
+	writeDirEntryAt(buf, bodyOffset, wz.DirEntryTypeFile, "", 10, 1, 0)
+	writeDirEntryAt(buf, bodyOffset, wz.DirEntryTypeFile, "Empty.img", 0, 0, 0)
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r := parser.NewStreamReader(streamOnly{bytes.NewReader(buf.Bytes())}, &config.Config{}, logger)
+	setReaderField(t, r, "key", wz.NewKey([4]byte{0x4D, 0x23, 0xC7, 0x2B}))
+	setReaderField(t, r, "versionHash", testVersionHash)
+
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatalf("ReadHeader() failed: %v", err)
+	}
+	if _, err := r.ReadVersionHeader(); err != nil {
+		t.Fatalf("ReadVersionHeader() failed: %v", err)
+	}
+	root, err := r.ReadTree()
+	if err != nil {
+		t.Fatalf("ReadTree() failed: %v", err)
+	}
+
+	// the same entries as WzReader keeps, with the same logs
+	if len(root.EntriesMetadata) != 1 || root.EntriesMetadata[0].Name != "Empty.img" {
+		t.Fatalf("EntriesMetadata = %+v, want only Empty.img", root.EntriesMetadata)
+	}
+	for _, msg := range []string{"skipping directory entry with empty name", "directory entry is an empty image"} {
+		if !strings.Contains(logs.String(), msg) {
+			t.Errorf("logs are missing %q", msg)
+		}
+	}
+}
+
+func TestStreamReader_ReadVersionHeader(t *testing.T) {
+	for _, tt := range versionHeaderTests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append(buildValidHeader(1000, "test"), tt.data...)
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			r := parser.NewStreamReader(streamOnly{bytes.NewReader(data)}, &config.Config{}, logger)
+			setReaderField(t, r, "key", wz.NewKey([4]byte{0x4D, 0x23, 0xC7, 0x2B}))
+			setReaderField(t, r, "versionHash", testVersionHash)
+
+			if _, err := r.ReadHeader(); err != nil {
+				t.Fatalf("ReadHeader() failed: %v", err)
+			}
+			got, err := r.ReadVersionHeader()
+			if err != nil {
+				t.Fatalf("ReadVersionHeader() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadVersionHeader() = %#x, want %#x", got, tt.want)
+			}
+
+			// the reader should be left at the root directory; the
+			// 256-entry root can't be read in full
+			if tt.wantCount != 1 {
+				return
+			}
+			root, err := r.ReadTree()
+			if err != nil {
+				t.Fatalf("ReadTree() failed: %v", err)
+			}
+			if _, ok := root.Find("Mob.img"); !ok {
+				t.Errorf("root entries = %+v, want Mob.img", root.EntriesMetadata)
+			}
+		})
+	}
+}
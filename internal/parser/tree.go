@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+// PathError records a failure to read the WZ node at Path.
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// ReadTree reads the directory at the current position and,
+// recursively, every subdirectory below it.
+//
+// If the reader isn't failing fast (see SetFailFast), a subdirectory
+// that fails to read is recorded as a *PathError and skipped, and
+// reading continues with its siblings. The partial tree is returned
+// along with all recorded errors joined together; they are also
+// available from Errors. Otherwise the first error aborts the read.
+func (r *WzReader) ReadTree() (*wz.Dir, error) {
+	r.errs = nil
+
+	root, err := r.ReadDir()
+	if err != nil {
+		return nil, err
+	}
+	if len(r.fallbackKeys) > 0 {
+		r.logEntryRegions(root)
+	}
+	if r.config != nil && r.config.StrictRootNames {
+		if err := checkRootNames(r.logger, root); err != nil {
+			return nil, err
+		}
+	}
+
+	visited := make(map[uint32]bool)
+	if err := r.readSubdirs(root, "", visited, &r.errs); err != nil {
+		return nil, err
+	}
+
+	return root, errors.Join(r.errs...)
+}
+
+// SetFailFast sets whether ReadTree aborts at the first unreadable
+// directory (the default) or records the error and continues.
+// Parse sets it from config.ContinueOnError.
+func (r *WzReader) SetFailFast(failFast bool) {
+	r.collectErrors = !failFast
+}
+
+// Errors returns the errors recorded by the last ReadTree when not
+// failing fast, in the order they occurred.
+func (r *WzReader) Errors() []error {
+	return slices.Clone(r.errs)
+}
+
+// readSubdirs reads the subdirectories of d, whose path is dirPath.
+// Failures are appended to errs when continuing on error, and
+// returned otherwise.
+func (r *WzReader) readSubdirs(d *wz.Dir, dirPath string, visited map[uint32]bool, errs *[]error) error {
+	for _, entry := range d.EntriesMetadata {
+		if entry.Type != wz.DirEntryTypeDir {
+			continue
+		}
+
+		entryPath := entry.Name
+		if dirPath != "" {
+			entryPath = dirPath + "/" + entry.Name
+		}
+
+		sub, err := r.readSubdir(entry, visited)
+		if err == nil {
+			err = r.readSubdirs(sub, entryPath, visited, errs)
+		}
+		if err != nil {
+			var pathErr *PathError
+			if !errors.As(err, &pathErr) {
+				err = &PathError{Path: entryPath, Err: err}
+			}
+			if !r.continueOnError() {
+				return err
+			}
+
+			r.logger.Warn("skipping unreadable directory",
+				"path", entryPath,
+				"error", err,
+			)
+			*errs = append(*errs, err)
+			continue
+		}
+
+		d.Subdirs = append(d.Subdirs, sub)
+	}
+
+	return nil
+}
+
+// readSubdir reads the directory that entry points to.
+func (r *WzReader) readSubdir(entry wz.DirEntryMetadata, visited map[uint32]bool) (*wz.Dir, error) {
+	// a directory pointing back at an ancestor would recurse forever
+	if visited[entry.DataOffset] {
+		return nil, fmt.Errorf("directory offset %d already visited", entry.DataOffset)
+	}
+	visited[entry.DataOffset] = true
+
+	if _, err := r.file.Seek(int64(entry.DataOffset), io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek to directory at offset %d: %w", entry.DataOffset, err)
+	}
+
+	sub, err := r.ReadDir()
+	if err != nil {
+		return nil, err
+	}
+	sub.Name = entry.Name
+
+	return sub, nil
+}
+
+// logEntryRegions logs which region's key decrypted the name of each
+// top-level entry, when reading with fallback keys.
+func (r *WzReader) logEntryRegions(root *wz.Dir) {
+	primary := primaryRegion(r.config.GameRegion)
+	for _, entry := range root.EntriesMetadata {
+		region := entry.Region
+		if region == "" {
+			region = primary
+		}
+		r.logger.Info("decrypted top-level entry",
+			"name", entry.Name,
+			"region", region,
+		)
+	}
+}
+
+// continueOnError reports whether read errors should be collected
+// rather than aborting the parse.
+func (r *WzReader) continueOnError() bool {
+	return r.collectErrors
+}
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/ossyrian/mintyparse/internal/config"
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+// validateEntries is how many root directory entries Validate checks.
+const validateEntries = 8
+
+// Validate checks that file can be decrypted with the settings in cfg,
+// without reading the whole tree: it reads the header, determines the
+// version hash, and checks that the first few root directory entries
+// decrypt to valid names with data offsets inside the file.
+//
+// The returned Result's Root holds only the entries that were checked.
+// With cfg.AutoRegion, other regions are tried if the configured one
+// fails (see withAutoRegion).
+func Validate(file io.ReadSeeker, cfg *config.Config) (*Result, error) {
+	if cfg.AutoRegion {
+		return withAutoRegion(file, cfg, validate)
+	}
+	return validate(file, cfg)
+}
+
+// validate is Validate for a single region configuration.
+func validate(file io.ReadSeeker, cfg *config.Config) (*Result, error) {
+	logger := slog.With(
+		"file", cfg.InputFile,
+	)
+
+	logger.Info("starting validation")
+
+	reader, err := openReader(file, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &wz.Dir{}
+	if err := wz.ReadCompressedInt32(reader.file, &root.EntryCount); err != nil {
+		return nil, fmt.Errorf("failed to read root entry count: %w", err)
+	}
+	if root.EntryCount < 0 {
+		return nil, fmt.Errorf("invalid root entry count: %d", root.EntryCount)
+	}
+
+	bodyEnd := uint64(reader.header.BodyOffset) + reader.header.BodySize
+	for i := 0; i < int(min(root.EntryCount, validateEntries)); i++ {
+		entry, err := reader.readDirEntryMetadata(i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read root entry %d: %w", i, err)
+		}
+		if entry == nil {
+			continue
+		}
+
+		if !isValidWzName(entry.Name) {
+			return nil, fmt.Errorf("root entry %d did not decrypt to a valid name: %q", i, entry.Name)
+		}
+		if uint64(entry.DataOffset) >= bodyEnd {
+			return nil, fmt.Errorf("root entry %s has data offset %d past the end of the file (%d)",
+				entry.Name, entry.DataOffset, bodyEnd)
+		}
+
+		root.EntriesMetadata = append(root.EntriesMetadata, *entry)
+	}
+
+	return &Result{
+		Header:        reader.header,
+		Root:          root,
+		VersionHeader: reader.versionHeader,
+		Version:       reader.version,
+		VersionHash:   reader.versionHash,
+	}, nil
+}
@@ -0,0 +1,59 @@
+package selfcheck
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ossyrian/mintyparse/internal/config"
+	"github.com/ossyrian/mintyparse/internal/parser"
+	"github.com/ossyrian/mintyparse/internal/wz"
+	"github.com/ossyrian/mintyparse/internal/wztest"
+)
+
+// Run builds a minimal WZ file in memory with the encryption and writing
+// primitives, parses it back and checks that the result matches what was
+// written. A failure usually points at a broken environment (e.g. AES)
+// rather than a bad input file.
+//
+// The file holds a root directory with one subdirectory and two images.
+// Images are written as opaque bytes, since image properties aren't
+// parsed yet.
+func Run() error {
+	root := &wz.Dir{
+		EntriesMetadata: []wz.DirEntryMetadata{
+			{Type: wz.DirEntryTypeDir, Name: "Mob", FileSize: 0, Checksum: 0},
+			{Type: wz.DirEntryTypeFile, Name: "Möbius.img", FileSize: 4, Checksum: -5},
+		},
+		Subdirs: []*wz.Dir{{
+			Name: "Mob",
+			EntriesMetadata: []wz.DirEntryMetadata{
+				{Type: wz.DirEntryTypeFile, Name: "0100100.img", FileSize: 4, Checksum: 1000},
+			},
+		}},
+	}
+
+	file, want, err := wztest.BuildFile(root, wztest.Options{})
+	if err != nil {
+		return fmt.Errorf("failed to build fixture: %w", err)
+	}
+
+	cfg := &config.Config{
+		InputFile:   "selfcheck",
+		GameRegion:  wztest.DefaultRegion,
+		GameVersion: wztest.DefaultVersion,
+	}
+
+	got, err := parser.Parse(file, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to parse fixture: %w", err)
+	}
+
+	if got.Header.Copyright != wztest.DefaultCopyright {
+		return fmt.Errorf("copyright mismatch: got %q, want %q", got.Header.Copyright, wztest.DefaultCopyright)
+	}
+	if !reflect.DeepEqual(got.Root, want) {
+		return fmt.Errorf("directory tree mismatch:\ngot  %+v\nwant %+v", got.Root, want)
+	}
+
+	return nil
+}
@@ -0,0 +1,17 @@
+package selfcheck_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/ossyrian/mintyparse/internal/selfcheck"
+)
+
+func TestRun(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if err := selfcheck.Run(); err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+// Package server serves a parsed WZ directory tree over HTTP as JSON,
+// as a backend for asset browsers.
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+	"github.com/ossyrian/mintyparse/internal/wztypes"
+)
+
+// Node is the JSON form of a directory, as served under /node/.
+type Node struct {
+	Name    string  `json:"name"`
+	Path    string  `json:"path"`
+	Entries []Entry `json:"entries"`
+}
+
+// Entry is the JSON form of a directory entry.
+type Entry struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // "dir", "image" or "ref"
+	Size     int32  `json:"size"`
+	Checksum int32  `json:"checksum"`
+	Offset   uint32 `json:"offset"`
+}
+
+// Property is the JSON form of an image, or of a property in one, as
+// served under /node/.
+type Property struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Type  string `json:"type"` // "image", or the wztypes.PropertyType name
+	Value any    `json:"value"`
+}
+
+// entryTypes names the entry types listed in a Node. Ignored
+// entries have no name and are left out.
+var entryTypes = map[wz.DirEntryType]string{
+	wz.DirEntryTypeReference: "ref",
+	wz.DirEntryTypeDir:       "dir",
+	wz.DirEntryTypeFile:      "image",
+}
+
+// ImageReader reads the image a directory entry points at, e.g. a
+// parser.WzReader.
+type ImageReader interface {
+	ReadImage(entry *wz.DirEntryMetadata) (*wztypes.WzImage, error)
+}
+
+// Handler returns a handler serving root:
+//   - GET /node/<path> returns the directory at the slash-separated
+//     path (the root for an empty path) as a Node. A path through an
+//     image returns the image, or the property below it, as a Property;
+//     images are read with images on each request
+//   - GET /sprite/<path> returns 501 Not Implemented, as canvas pixel
+//     data is not decoded yet
+//
+// images is only called by one request at a time, since readers that
+// seek in a shared file are not safe for concurrent use.
+func Handler(logger *slog.Logger, root *wz.Dir, images ImageReader) http.Handler {
+	s := &server{logger: logger, root: root, images: images}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /node/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		s.serveNode(w, r, r.PathValue("path"))
+	})
+	mux.HandleFunc("GET /sprite/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "sprites are not supported yet", http.StatusNotImplemented)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Debug("request", "method", r.Method, "path", r.URL.Path)
+		mux.ServeHTTP(w, r)
+	})
+}
+
+type server struct {
+	logger *slog.Logger
+	root   *wz.Dir
+
+	mu     sync.Mutex // serializes calls to images
+	images ImageReader
+}
+
+// serveNode writes the directory, image or property at path as JSON.
+func (s *server) serveNode(w http.ResponseWriter, r *http.Request, path string) {
+	var names []string
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	dir := s.root
+	for i, name := range names {
+		entry, ok := dir.Find(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if entry.Type == wz.DirEntryTypeFile {
+			s.serveImage(w, r, entry, strings.Join(names[:i+1], "/"), names[i+1:])
+			return
+		}
+		sub, ok := dir.Subdir(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		dir = sub
+	}
+
+	node := Node{
+		Name:    dir.Name,
+		Path:    strings.Join(names, "/"),
+		Entries: make([]Entry, 0, len(dir.EntriesMetadata)),
+	}
+	for _, entry := range dir.EntriesMetadata {
+		typ, ok := entryTypes[entry.Type]
+		if !ok {
+			continue
+		}
+		node.Entries = append(node.Entries, Entry{
+			Name:     entry.Name,
+			Type:     typ,
+			Size:     entry.FileSize,
+			Checksum: entry.Checksum,
+			Offset:   entry.DataOffset,
+		})
+	}
+
+	writeJSON(w, node)
+}
+
+// serveImage writes the image entry at imagePath as JSON, or the
+// property at propPath inside it if that is not empty.
+func (s *server) serveImage(w http.ResponseWriter, r *http.Request, entry *wz.DirEntryMetadata, imagePath string, propPath []string) {
+	s.mu.Lock()
+	img, err := s.images.ReadImage(entry)
+	s.mu.Unlock()
+	if err != nil {
+		s.logger.Error("failed to read image",
+			"path", imagePath,
+			"error", err)
+		http.Error(w, "failed to read image", http.StatusInternalServerError)
+		return
+	}
+
+	if len(propPath) == 0 {
+		writeJSON(w, Property{
+			Name:  img.Name,
+			Path:  imagePath,
+			Type:  "image",
+			Value: (&wztypes.WzSubProperty{Properties: img.Properties}).GetValue(),
+		})
+		return
+	}
+
+	prop, ok := img.Get(propPath...)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, Property{
+		Name:  prop.GetName(),
+		Path:  imagePath + "/" + strings.Join(propPath, "/"),
+		Type:  prop.GetType().String(),
+		Value: prop.GetValue(),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
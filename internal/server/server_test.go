@@ -0,0 +1,146 @@
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ossyrian/mintyparse/internal/server"
+	"github.com/ossyrian/mintyparse/internal/wz"
+	"github.com/ossyrian/mintyparse/internal/wztypes"
+)
+
+// fakeImages serves images by entry name
+type fakeImages map[string]*wztypes.WzImage
+
+func (f fakeImages) ReadImage(entry *wz.DirEntryMetadata) (*wztypes.WzImage, error) {
+	img, ok := f[entry.Name]
+	if !ok {
+		return nil, errors.New("no such image")
+	}
+	return img, nil
+}
+
+func TestHandler(t *testing.T) {
+	root := &wz.Dir{
+		EntriesMetadata: []wz.DirEntryMetadata{
+			{Type: wz.DirEntryTypeIgnore},
+			{Type: wz.DirEntryTypeDir, Name: "Mob", FileSize: 10, DataOffset: 100},
+			{Type: wz.DirEntryTypeFile, Name: "Base.img", FileSize: 4, Checksum: 7, DataOffset: 200},
+		},
+		Subdirs: []*wz.Dir{{
+			Name: "Mob",
+			EntriesMetadata: []wz.DirEntryMetadata{
+				{Type: wz.DirEntryTypeFile, Name: "0100100.img", FileSize: 8, DataOffset: 300},
+			},
+		}},
+	}
+	images := fakeImages{
+		"0100100.img": {Name: "0100100.img", Properties: []wztypes.WzProperty{
+			&wztypes.WzSubProperty{Name: "info", Properties: []wztypes.WzProperty{
+				&wztypes.WzIntProperty{Name: "speed", Value: -30},
+			}},
+		}},
+	}
+	srv := httptest.NewServer(server.Handler(slog.New(slog.DiscardHandler), root, images))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantNode   *server.Node
+		wantProp   *server.Property
+	}{
+		{
+			name:       "root",
+			path:       "/node/",
+			wantStatus: http.StatusOK,
+			wantNode: &server.Node{
+				Entries: []server.Entry{
+					{Name: "Mob", Type: "dir", Size: 10, Offset: 100},
+					{Name: "Base.img", Type: "image", Size: 4, Checksum: 7, Offset: 200},
+				},
+			},
+		},
+		{
+			name:       "subdirectory",
+			path:       "/node/Mob",
+			wantStatus: http.StatusOK,
+			wantNode: &server.Node{
+				Name: "Mob",
+				Path: "Mob",
+				Entries: []server.Entry{
+					{Name: "0100100.img", Type: "image", Size: 8, Offset: 300},
+				},
+			},
+		},
+		{
+			name:       "image",
+			path:       "/node/Mob/0100100.img",
+			wantStatus: http.StatusOK,
+			wantProp: &server.Property{
+				Name:  "0100100.img",
+				Path:  "Mob/0100100.img",
+				Type:  "image",
+				Value: map[string]any{"info": map[string]any{"speed": -30.0}},
+			},
+		},
+		{
+			name:       "property",
+			path:       "/node/Mob/0100100.img/info/speed",
+			wantStatus: http.StatusOK,
+			wantProp: &server.Property{
+				Name:  "speed",
+				Path:  "Mob/0100100.img/info/speed",
+				Type:  "int",
+				Value: -30.0,
+			},
+		},
+		{name: "missing property", path: "/node/Mob/0100100.img/info/level", wantStatus: http.StatusNotFound},
+		{name: "unreadable image", path: "/node/Base.img", wantStatus: http.StatusInternalServerError},
+		{name: "missing", path: "/node/Npc", wantStatus: http.StatusNotFound},
+		{name: "sprite", path: "/sprite/Mob/0100100.img/stand/0", wantStatus: http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				body, _ := io.ReadAll(resp.Body)
+				t.Fatalf("status = %d, want %d (body %q)", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantProp != nil {
+				var got server.Property
+				if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+					t.Fatalf("failed to decode property: %v", err)
+				}
+				if !reflect.DeepEqual(&got, tt.wantProp) {
+					t.Errorf("property = %+v, want %+v", got, *tt.wantProp)
+				}
+				return
+			}
+			if tt.wantNode == nil {
+				return
+			}
+
+			var got server.Node
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode node: %v", err)
+			}
+			if !reflect.DeepEqual(&got, tt.wantNode) {
+				t.Errorf("node = %+v, want %+v", got, *tt.wantNode)
+			}
+		})
+	}
+}
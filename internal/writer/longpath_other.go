@@ -0,0 +1,8 @@
+//go:build !windows
+
+package writer
+
+// longPath returns p unchanged; only Windows limits path length.
+func longPath(p string) string {
+	return p
+}
@@ -0,0 +1,23 @@
+package writer
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// maxPath is the Windows MAX_PATH limit.
+const maxPath = 260
+
+// longPath returns p with the \\?\ extended-length prefix if it would
+// otherwise exceed MAX_PATH.
+func longPath(p string) string {
+	if len(p) < maxPath || strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	return `\\?\` + abs
+}
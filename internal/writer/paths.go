@@ -0,0 +1,105 @@
+package writer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// shortSegmentLen is the length a segment is cut to before
+// appending its hash when shortening a path.
+const shortSegmentLen = 8
+
+// PathMapFile is the file, in the output directory, that maps
+// shortened paths back to the original WZ paths.
+const PathMapFile = "path-map.tsv"
+
+// pathShortener keeps output paths under a length cap by replacing
+// overly long segments with a truncated, hashed form, remembering each
+// replacement so the original paths can be recovered.
+type pathShortener struct {
+	outDir  string
+	maxPath int               // 0 means no cap
+	mapping map[string]string // shortened relative path -> original
+}
+
+func newPathShortener(outDir string, maxPath int) *pathShortener {
+	return &pathShortener{
+		outDir:  outDir,
+		maxPath: maxPath,
+		mapping: make(map[string]string),
+	}
+}
+
+// shorten returns the relative output path for the slash-separated WZ
+// path rel. Segments are shortened starting with the longest until the
+// full path fits the cap. Shortening is deterministic, so siblings with
+// the same long parent still share a directory.
+func (s *pathShortener) shorten(rel string) string {
+	if s.maxPath <= 0 || len(filepath.Join(s.outDir, rel)) <= s.maxPath {
+		return rel
+	}
+
+	segments := strings.Split(rel, "/")
+	for len(filepath.Join(s.outDir, strings.Join(segments, "/"))) > s.maxPath {
+		longest := -1
+		for i, seg := range segments {
+			if len(seg) > shortSegmentLen+len("~")+8 && (longest < 0 || len(seg) > len(segments[longest])) {
+				longest = i
+			}
+		}
+		if longest < 0 {
+			break // nothing left to shorten
+		}
+		segments[longest] = shortSegment(segments[longest])
+	}
+
+	short := strings.Join(segments, "/")
+	if short != rel {
+		s.mapping[short] = rel
+	}
+	return short
+}
+
+// shortSegment shortens a path segment to its first few characters
+// followed by a hash of the whole segment, e.g. "Obj~1a2b3c4d". The
+// cut is moved back to a rune boundary so multi-byte names stay valid
+// UTF-8.
+func shortSegment(seg string) string {
+	cut := shortSegmentLen
+	for cut > 0 && !utf8.RuneStart(seg[cut]) {
+		cut--
+	}
+
+	sum := sha256.Sum256([]byte(seg))
+	return seg[:cut] + "~" + hex.EncodeToString(sum[:4])
+}
+
+// writeMapping writes the shortened paths, if any, to PathMapFile
+// as "shortened<TAB>original" lines.
+func (s *pathShortener) writeMapping() error {
+	if len(s.mapping) == 0 {
+		return nil
+	}
+
+	shorts := make([]string, 0, len(s.mapping))
+	for short := range s.mapping {
+		shorts = append(shorts, short)
+	}
+	sort.Strings(shorts)
+
+	var b strings.Builder
+	for _, short := range shorts {
+		fmt.Fprintf(&b, "%s\t%s\n", short, s.mapping[short])
+	}
+
+	if err := os.WriteFile(filepath.Join(s.outDir, PathMapFile), []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write path map: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package writer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+// EncryptedSuffix is appended to an image's path to name the sidecar
+// file holding its raw encrypted bytes (see DumpTreeDir).
+const EncryptedSuffix = ".enc"
+
+// DumpTreeDir creates an empty directory under outDir for every directory
+// and image below root, mirroring the WZ hierarchy so it can be browsed
+// in a file explorer. Images become directories named after them (e.g.
+// "Mob/0100100.img/"); no property data is written.
+//
+// If maxPath is positive, paths longer than it are shortened by hashing
+// their longest segments, and the shortened paths are listed in
+// PathMapFile. On Windows, paths over MAX_PATH are created with the
+// extended-length prefix, so a cap is only needed for tools that don't
+// support it.
+//
+// If raw is non-nil, it must be the file root was read from. The
+// encrypted bytes of each image (FileSize bytes at DataOffset) are then
+// copied unmodified into a sidecar file next to its directory, named
+// after it with EncryptedSuffix (e.g. "Mob/0100100.img.enc"), and
+// maxPath applies to the sidecar paths too.
+func DumpTreeDir(root *wz.Dir, outDir string, maxPath int, raw io.ReadSeeker) error {
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create tree directory: %w", err)
+	}
+
+	// leave room for the suffix, so sidecars fit the cap too
+	if raw != nil && maxPath > len(EncryptedSuffix) {
+		maxPath -= len(EncryptedSuffix)
+	}
+	shortener := newPathShortener(outDir, maxPath)
+	err := root.Walk(func(path string, entry *wz.DirEntryMetadata) error {
+		// names come from the file, so don't let them escape outDir
+		if !filepath.IsLocal(path) {
+			return fmt.Errorf("refusing to create %q outside the tree directory", path)
+		}
+
+		dir := filepath.Join(outDir, filepath.FromSlash(shortener.shorten(path)))
+		if err := os.MkdirAll(longPath(dir), 0o755); err != nil {
+			return fmt.Errorf("failed to create tree directory: %w", err)
+		}
+
+		if raw != nil && entry.Type == wz.DirEntryTypeFile {
+			return copyEncrypted(raw, entry, dir+EncryptedSuffix)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return shortener.writeMapping()
+}
+
+// copyEncrypted copies the raw bytes of the image entry from raw to path.
+func copyEncrypted(raw io.ReadSeeker, entry *wz.DirEntryMetadata, path string) error {
+	if _, err := raw.Seek(int64(entry.DataOffset), io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek to %s: %w", entry.Name, err)
+	}
+
+	out, err := os.Create(longPath(path))
+	if err != nil {
+		return fmt.Errorf("failed to create encrypted sidecar: %w", err)
+	}
+	if _, err := io.CopyN(out, raw, int64(entry.FileSize)); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy encrypted bytes of %s: %w", entry.Name, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,177 @@
+package writer_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ossyrian/mintyparse/internal/writer"
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+func TestDumpTreeDir(t *testing.T) {
+	longName := strings.Repeat("VeryLongDirectoryName", 4)
+
+	deep := &wz.Dir{
+		Name: longName,
+		EntriesMetadata: []wz.DirEntryMetadata{
+			{Type: wz.DirEntryTypeFile, Name: "0100100.img"},
+		},
+	}
+	root := &wz.Dir{
+		EntriesMetadata: []wz.DirEntryMetadata{
+			{Type: wz.DirEntryTypeDir, Name: longName},
+			{Type: wz.DirEntryTypeFile, Name: "Foo.img"},
+		},
+		Subdirs: []*wz.Dir{deep},
+	}
+
+	t.Run("no cap", func(t *testing.T) {
+		outDir := t.TempDir()
+		if err := writer.DumpTreeDir(root, outDir, 0, nil); err != nil {
+			t.Fatalf("DumpTreeDir() failed: %v", err)
+		}
+
+		for _, path := range []string{"Foo.img", longName + "/0100100.img"} {
+			if _, err := os.Stat(filepath.Join(outDir, path)); err != nil {
+				t.Errorf("missing %s: %v", path, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(outDir, writer.PathMapFile)); !os.IsNotExist(err) {
+			t.Errorf("wrote %s without shortening anything", writer.PathMapFile)
+		}
+	})
+
+	t.Run("shortens long paths", func(t *testing.T) {
+		outDir := t.TempDir()
+		maxPath := len(outDir) + 40
+		if err := writer.DumpTreeDir(root, outDir, maxPath, nil); err != nil {
+			t.Fatalf("DumpTreeDir() failed: %v", err)
+		}
+
+		err := filepath.Walk(outDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if len(path) > maxPath {
+				t.Errorf("%s is longer than %d", path, maxPath)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mapping, err := os.ReadFile(filepath.Join(outDir, writer.PathMapFile))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", writer.PathMapFile, err)
+		}
+		if !strings.Contains(string(mapping), "\t"+longName+"/0100100.img\n") {
+			t.Errorf("%s = %q, want the original path of the shortened image", writer.PathMapFile, mapping)
+		}
+		if strings.Contains(string(mapping), "Foo.img") {
+			t.Errorf("%s = %q, want only shortened paths", writer.PathMapFile, mapping)
+		}
+	})
+
+	t.Run("shortens multi-byte names", func(t *testing.T) {
+		// the 8-byte cut falls inside the third rune
+		name := "a" + strings.Repeat("몬스터", 10)
+		root := &wz.Dir{
+			EntriesMetadata: []wz.DirEntryMetadata{{Type: wz.DirEntryTypeFile, Name: name}},
+		}
+
+		outDir := t.TempDir()
+		if err := writer.DumpTreeDir(root, outDir, len(outDir)+40, nil); err != nil {
+			t.Fatalf("DumpTreeDir() failed: %v", err)
+		}
+
+		entries, err := os.ReadDir(outDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if !utf8.ValidString(e.Name()) {
+				t.Errorf("shortened name %q is not valid UTF-8", e.Name())
+			}
+			if e.Name() != writer.PathMapFile && !strings.HasPrefix(e.Name(), "a몬스~") {
+				t.Errorf("shortened name = %q, want prefix %q", e.Name(), "a몬스~")
+			}
+		}
+	})
+}
+
+func TestDumpTreeDir_KeepEncrypted(t *testing.T) {
+	raw := []byte("headerMOBDATAfoo")
+	mob := &wz.Dir{
+		Name: "Mob",
+		EntriesMetadata: []wz.DirEntryMetadata{
+			{Type: wz.DirEntryTypeFile, Name: "0100100.img", FileSize: 7, DataOffset: 6},
+		},
+	}
+	root := &wz.Dir{
+		EntriesMetadata: []wz.DirEntryMetadata{
+			{Type: wz.DirEntryTypeDir, Name: "Mob"},
+			{Type: wz.DirEntryTypeFile, Name: "Foo.img", FileSize: 3, DataOffset: 13},
+		},
+		Subdirs: []*wz.Dir{mob},
+	}
+
+	outDir := t.TempDir()
+	if err := writer.DumpTreeDir(root, outDir, 0, bytes.NewReader(raw)); err != nil {
+		t.Fatalf("DumpTreeDir() failed: %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"Mob/0100100.img" + writer.EncryptedSuffix: "MOBDATA",
+		"Foo.img" + writer.EncryptedSuffix:         "foo",
+	} {
+		got, err := os.ReadFile(filepath.Join(outDir, path))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v, want %q", path, got, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "Mob"+writer.EncryptedSuffix)); !os.IsNotExist(err) {
+		t.Errorf("wrote a sidecar for a directory")
+	}
+}
+
+func TestDumpTreeDir_KeepEncryptedMaxPath(t *testing.T) {
+	longName := strings.Repeat("VeryLongImageName", 4) + ".img"
+	raw := []byte("data")
+	root := &wz.Dir{
+		EntriesMetadata: []wz.DirEntryMetadata{
+			{Type: wz.DirEntryTypeFile, Name: longName, FileSize: 4},
+		},
+	}
+
+	outDir := t.TempDir()
+	// the image directory alone would fit, but not with the suffix
+	maxPath := len(filepath.Join(outDir, longName)) + len(writer.EncryptedSuffix) - 1
+	if err := writer.DumpTreeDir(root, outDir, maxPath, bytes.NewReader(raw)); err != nil {
+		t.Fatalf("DumpTreeDir() failed: %v", err)
+	}
+
+	var sidecars int
+	err := filepath.Walk(outDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if len(path) > maxPath {
+			t.Errorf("%s is longer than %d", path, maxPath)
+		}
+		if strings.HasSuffix(path, writer.EncryptedSuffix) {
+			sidecars++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sidecars != 1 {
+		t.Errorf("wrote %d sidecars, want 1", sidecars)
+	}
+}
@@ -1,8 +1,41 @@
 package writer
 
-type Writer struct{}
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Meta describes the provenance of a parse, so that
+// extracted datasets are self-describing.
+type Meta struct {
+	Copyright     string    `json:"copyright"`
+	VersionHeader uint16    `json:"version_header"` // raw version header value (0 if not present)
+	Version       string    `json:"version"`        // MapleStory version, provided or detected
+	VersionHash   uint32    `json:"version_hash"`
+	Region        string    `json:"region"`
+	ToolVersion   string    `json:"tool_version"` // mintyparse version
+	ParsedAt      time.Time `json:"parsed_at"`
+}
+
+type Writer struct {
+	Meta Meta
+}
 
 // Write writes parsed data to a JSON IR.
-func (w *Writer) Write() {
-	// TODO: implement
+// The top-level object always carries a "_meta" object (see Meta).
+func (w *Writer) Write(out io.Writer) error {
+	doc := struct {
+		Meta Meta `json:"_meta"`
+	}{
+		Meta: w.Meta,
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return nil
 }
@@ -5,6 +5,21 @@ import "fmt"
 // Magic is the magic number identifying valid WZ files ("PKG1")
 var Magic = [4]byte{'P', 'K', 'G', '1'}
 
+// String block indicators, see ReadOffsetOrInlineString.
+const (
+	// StringInline marks a property name stored inline.
+	StringInline byte = 0x00
+	// StringOffset marks a property name stored at an image-relative offset.
+	StringOffset byte = 0x01
+	// StringInlineExtended marks an extended type name stored inline.
+	StringInlineExtended byte = 0x73
+	// StringOffsetExtended marks an extended type name stored at an image-relative offset.
+	StringOffsetExtended byte = 0x1B
+)
+
+// Regions lists the game regions IVForVersion knows, most common first.
+var Regions = []string{"gms", "kms", "sea", "tms"}
+
 // IVForVersion returns the initialization vector (IV) bytes for known game versions/regions.
 func IVForVersion(region string) ([]byte, error) {
 	switch region {
@@ -2,13 +2,15 @@ package wz
 
 import (
 	"crypto/aes"
+	"crypto/cipher"
 	"encoding/binary"
 	"fmt"
 )
 
 // Constants for WZ encryption
 const (
-	// OffsetConstant is used in WZ offset decryption.
+	// OffsetConstant is used in WZ offset decryption by all official
+	// clients; some modified private server clients change it.
 	// Reference: MapleLib WzAESConstant.WZ_OffsetConstant
 	OffsetConstant = 0x581C3F6D
 
@@ -55,6 +57,15 @@ type Key struct {
 	iv      [4]byte  // Initialization vector for this WZ file
 	aesKey  [32]byte // AES key for key stream generation (see NewKey for how it's derived)
 	keyData []byte   // Generated key stream (expanded on demand)
+
+	// maxRetained caps len(keyData) (0 means no cap, see SetMaxRetained).
+	// Key bytes past it are generated on the fly from the last retained
+	// block, keeping only the most recent block in tail.
+	maxRetained int
+	tailPos     int // key stream index of tail[0], or -1 if tail is unset
+	tail        [16]byte
+
+	block cipher.Block // AES cipher, created on first use
 }
 
 // NewKey creates a new WZ key generator from an initialization vector.
@@ -78,19 +89,82 @@ func NewKey(iv [4]byte) *Key {
 	}
 
 	return &Key{
-		iv:     iv,
-		aesKey: aesKey,
+		iv:      iv,
+		aesKey:  aesKey,
+		tailPos: -1,
 	}
 }
 
+// SetMaxRetained caps how many bytes of key stream the Key keeps in
+// memory, rounded up to a multiple of KeyBatchSize; 0 means no cap.
+//
+// Without a cap, a single very long string (e.g. in String.wz) grows the
+// retained key stream to its length for the life of the Key. With one,
+// bytes past the cap are regenerated as needed instead. Strings read
+// their key bytes in order, so this costs one AES block per 16 bytes
+// rather than restarting the chain for every byte.
+func (k *Key) SetMaxRetained(n int) {
+	if n > 0 {
+		n = ((n + KeyBatchSize - 1) / KeyBatchSize) * KeyBatchSize
+	}
+	k.maxRetained = n
+	if n > 0 && len(k.keyData) > n {
+		k.keyData = k.keyData[:n:n]
+	}
+	k.tailPos = -1
+}
+
 // ByteAt returns the key byte at the given index.
 // If the key stream has not been generated up to this index,
 // it will be expanded automatically.
 func (k *Key) ByteAt(index int) byte {
+	if k.maxRetained > 0 && index >= k.maxRetained {
+		return k.byteBeyondRetained(index)
+	}
+
 	k.expandTo(index + 1)
 	return k.keyData[index]
 }
 
+// byteBeyondRetained returns the key byte at index, which is past the
+// retained key stream, by continuing the block chain from the nearest
+// generated block.
+func (k *Key) byteBeyondRetained(index int) byte {
+	if k.iv == [4]byte{0, 0, 0, 0} {
+		return 0
+	}
+
+	blockPos := index - index%16
+	if k.tailPos < 0 || k.tailPos > blockPos {
+		// restart from the last retained block
+		k.expandTo(k.maxRetained)
+		k.tailPos = k.maxRetained - 16
+		copy(k.tail[:], k.keyData[k.tailPos:])
+	}
+
+	block := k.blockCipher()
+	for k.tailPos < blockPos {
+		block.Encrypt(k.tail[:], k.tail[:])
+		k.tailPos += 16
+	}
+
+	return k.tail[index-blockPos]
+}
+
+// blockCipher returns the AES cipher used to generate the key stream,
+// creating it on first use.
+func (k *Key) blockCipher() cipher.Block {
+	if k.block == nil {
+		block, err := aes.NewCipher(k.aesKey[:])
+		if err != nil {
+			// This should never happen with a valid 32-byte key
+			panic(fmt.Sprintf("failed to create AES cipher: %v", err))
+		}
+		k.block = block
+	}
+	return k.block
+}
+
 // expandTo expands the key stream to at least size bytes.
 // Keys are generated in KeyBatchSize (4096) byte batches.
 func (k *Key) expandTo(size int) {
@@ -113,11 +187,7 @@ func (k *Key) expandTo(size int) {
 	startIndex := copy(newData, k.keyData)
 
 	// Generate new key blocks using AES-256 ECB
-	block, err := aes.NewCipher(k.aesKey[:])
-	if err != nil {
-		// This should never happen with a valid 32-byte key
-		panic(fmt.Sprintf("failed to create AES cipher: %v", err))
-	}
+	block := k.blockCipher()
 
 	input := make([]byte, 16)
 	output := make([]byte, 16)
@@ -162,6 +232,50 @@ func (k *Key) DecryptString(encrypted []byte, isUnicode bool) string {
 	return k.decryptASCII(encrypted)
 }
 
+// DecryptKeyedString decrypts a WZ string that was encrypted with the key
+// stream in addition to the incrementing XOR mask used by DecryptString.
+//
+// In list.wz-era clients (roughly GMS v40-v75) only some image names are
+// encrypted this way, so this is used as a fallback when DecryptString
+// produces an invalid name.
+//
+// Reference: MapleLib WzBinaryReader.DecodeUnicode / DecodeAscii (key XOR)
+func (k *Key) DecryptKeyedString(encrypted []byte, isUnicode bool) string {
+	if isUnicode {
+		return k.decryptKeyedUnicode(encrypted)
+	}
+	return k.decryptKeyedASCII(encrypted)
+}
+
+// decryptKeyedUnicode decrypts a Unicode (UTF-16LE) WZ string with the key stream.
+func (k *Key) decryptKeyedUnicode(data []byte) string {
+	length := len(data) / 2
+	result := make([]rune, length)
+	mask := uint16(0xAAAA)
+
+	for i := 0; i < length; i++ {
+		encChar := binary.LittleEndian.Uint16(data[i*2:])
+		keyChar := uint16(k.ByteAt(i*2+1))<<8 | uint16(k.ByteAt(i*2))
+		result[i] = rune(encChar ^ mask ^ keyChar)
+		mask++
+	}
+
+	return string(result)
+}
+
+// decryptKeyedASCII decrypts an ASCII WZ string with the key stream.
+func (k *Key) decryptKeyedASCII(data []byte) string {
+	result := make([]byte, len(data))
+	mask := byte(0xAA)
+
+	for i := 0; i < len(data); i++ {
+		result[i] = data[i] ^ mask ^ k.ByteAt(i)
+		mask++
+	}
+
+	return string(result)
+}
+
 // decryptUnicode decrypts a Unicode (UTF-16LE) WZ string.
 // Reference: MapleLib WzBinaryReader.DecodeUnicode (lines 127-157)
 func (k *Key) decryptUnicode(data []byte) string {
@@ -192,8 +306,45 @@ func (k *Key) decryptASCII(data []byte) string {
 	return string(result)
 }
 
+// EncryptString encrypts s using the same XOR mask as DecryptString.
+// Strings containing only ASCII characters are encrypted as ASCII,
+// everything else as Unicode (UTF-16LE, one code unit per character).
+// The returned bool reports whether the result is Unicode.
+func (k *Key) EncryptString(s string) ([]byte, bool) {
+	runes := []rune(s)
+
+	isUnicode := false
+	for _, ch := range runes {
+		if ch > 0x7F {
+			isUnicode = true
+			break
+		}
+	}
+
+	if isUnicode {
+		result := make([]byte, len(runes)*2)
+		mask := uint16(0xAAAA)
+		for i, ch := range runes {
+			binary.LittleEndian.PutUint16(result[i*2:], uint16(ch)^mask)
+			mask++
+		}
+		return result, true
+	}
+
+	result := make([]byte, len(runes))
+	mask := byte(0xAA)
+	for i, ch := range runes {
+		result[i] = byte(ch) ^ mask
+		mask++
+	}
+	return result, false
+}
+
 // rotateLeft performs a left bitwise rotation on a 32-bit unsigned integer.
 // This is used in WZ offset decryption.
+//
+// n is at most 31. For n == 0, x >> 32 is 0 in Go (where C# would shift
+// by 0 instead), so the result is x either way.
 func rotateLeft(x uint32, n byte) uint32 {
 	return (x << n) | (x >> (32 - n))
 }
@@ -203,7 +354,7 @@ func rotateLeft(x uint32, n byte) uint32 {
 // The decryption algorithm:
 //  1. Calculate: (currentPos - bodyOffset) XOR 0xFFFFFFFF
 //  2. Multiply by version hash
-//  3. Subtract constant: 0x581C3F6D
+//  3. Subtract the offset constant (OffsetConstant for official clients)
 //  4. Rotate left by (result & 0x1F) bits
 //  5. XOR with the encrypted offset read from file
 //  6. Add bodyOffset × 2
@@ -212,21 +363,40 @@ func rotateLeft(x uint32, n byte) uint32 {
 //   - currentPos: The file position where the encrypted offset was read (before reading the 4 bytes)
 //   - bodyOffset: The offset where WZ data begins (from file header)
 //   - versionHash: The hash calculated from the MapleStory version number
+//   - offsetConstant: OffsetConstant, unless a modified client changed it
 //   - encryptedOffset: The 4-byte encrypted offset value read from the file
 //
 // Returns: The decrypted absolute file offset
 //
+// Overflow: every step is uint32 arithmetic that wraps modulo 2^32, as in
+// the client and MapleLib (which use C# uint). This is relied on, not a
+// bug: step 1 usually wraps, since currentPos - bodyOffset is small and
+// XORing it with 0xFFFFFFFF gives a value near 2^32, and steps 2, 3 and 6
+// can all wrap. Widening to uint64 would give different results. Offsets
+// (and positions) are 32-bit in the format itself, so files over 4 GiB
+// can't be addressed; callers truncate positions to uint32 the same way
+// MapleLib does.
+//
 // Reference: MapleLib WzBinaryReader.ReadOffset
-func DecryptOffset(currentPos, bodyOffset uint32, versionHash uint32, encryptedOffset uint32) uint32 {
+func DecryptOffset(currentPos, bodyOffset uint32, versionHash uint32, offsetConstant uint32, encryptedOffset uint32) uint32 {
 	offset := (currentPos - bodyOffset) ^ 0xFFFFFFFF
 	offset *= versionHash
-	offset -= OffsetConstant
+	offset -= offsetConstant
 	offset = rotateLeft(offset, byte(offset&0x1F))
 	offset ^= encryptedOffset
 	offset += bodyOffset * 2
 	return offset
 }
 
+// EncryptOffset is the inverse of DecryptOffset: it returns the encrypted
+// form of offset as it would be stored at currentPos.
+func EncryptOffset(currentPos, bodyOffset uint32, versionHash uint32, offsetConstant uint32, offset uint32) uint32 {
+	// DecryptOffset XORs the encrypted value in just before adding bodyOffset × 2,
+	// so decrypting zero yields that XOR mask plus bodyOffset × 2
+	mask := DecryptOffset(currentPos, bodyOffset, versionHash, offsetConstant, 0) - bodyOffset*2
+	return (offset - bodyOffset*2) ^ mask
+}
+
 // VersionHash calculates the version hash from a MapleStory version string.
 //
 // Algorithm:
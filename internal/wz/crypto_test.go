@@ -0,0 +1,143 @@
+package wz_test
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"runtime"
+	"testing"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+// referenceDecryptOffset is MapleLib's WzBinaryReader.ReadOffset, written
+// with explicit uint64 intermediates masked back to 32 bits to mirror C#
+// uint arithmetic step by step.
+func referenceDecryptOffset(currentPos, bodyOffset, versionHash, offsetConstant, encryptedOffset uint32) uint32 {
+	const mask = 1<<32 - 1
+
+	offset := (uint64(currentPos) - uint64(bodyOffset)) & mask
+	offset ^= mask
+	offset = (offset * uint64(versionHash)) & mask
+	offset = (offset - uint64(offsetConstant)) & mask
+	offset = uint64(bits.RotateLeft32(uint32(offset), int(offset&0x1F)))
+	offset ^= uint64(encryptedOffset)
+	offset = (offset + uint64(bodyOffset)*2) & mask
+	return uint32(offset)
+}
+
+func TestDecryptOffset(t *testing.T) {
+	// the "Mob" entry of a v83 GMS file with a 0x3B byte header
+	got := wz.DecryptOffset(0x45, 0x3B, wz.VersionHash("83"), wz.OffsetConstant, 0x042C0E55)
+	if got != 0x58 {
+		t.Errorf("DecryptOffset() = 0x%08X, want 0x00000058", got)
+	}
+}
+
+func TestDecryptOffset_Wrap(t *testing.T) {
+	tests := []struct {
+		name            string
+		currentPos      uint32
+		bodyOffset      uint32
+		versionHash     uint32
+		encryptedOffset uint32
+	}{
+		{name: "typical", currentPos: 0x45, bodyOffset: 0x3B, versionHash: wz.VersionHash("83"), encryptedOffset: 0x042C0E55},
+		{name: "position at body offset", currentPos: 0x3C, bodyOffset: 0x3C, versionHash: wz.VersionHash("83")},
+		{name: "position before body offset", currentPos: 0x10, bodyOffset: 0x3C, versionHash: 0x754},
+		{name: "position near 4 GiB", currentPos: math.MaxUint32 - 3, bodyOffset: 0x3C, versionHash: wz.VersionHash("230"), encryptedOffset: 0xDEADBEEF},
+		{name: "body offset doubling wraps", currentPos: math.MaxUint32, bodyOffset: 0x80000010, versionHash: 0xFFFF, encryptedOffset: math.MaxUint32},
+		{name: "max hash", currentPos: 0x1000, bodyOffset: 0x3C, versionHash: math.MaxUint32, encryptedOffset: 1},
+		{name: "zero hash", currentPos: 0x1000, bodyOffset: 0x3C, versionHash: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wz.DecryptOffset(tt.currentPos, tt.bodyOffset, tt.versionHash, wz.OffsetConstant, tt.encryptedOffset)
+			want := referenceDecryptOffset(tt.currentPos, tt.bodyOffset, tt.versionHash, wz.OffsetConstant, tt.encryptedOffset)
+			if got != want {
+				t.Errorf("DecryptOffset() = 0x%08X, want 0x%08X", got, want)
+			}
+
+			// EncryptOffset must invert it at the same boundaries
+			enc := wz.EncryptOffset(tt.currentPos, tt.bodyOffset, tt.versionHash, wz.OffsetConstant, got)
+			if enc != tt.encryptedOffset {
+				t.Errorf("EncryptOffset() = 0x%08X, want 0x%08X", enc, tt.encryptedOffset)
+			}
+		})
+	}
+}
+
+func TestKey_SetMaxRetained(t *testing.T) {
+	iv := [4]byte{0x4D, 0x23, 0xC7, 0x2B}
+	const n = 3*wz.KeyBatchSize + 100
+
+	full := wz.NewKey(iv)
+	want := make([]byte, n)
+	for i := range want {
+		want[i] = full.ByteAt(i)
+	}
+
+	capped := wz.NewKey(iv)
+	capped.SetMaxRetained(wz.KeyBatchSize)
+
+	// sequential access, as when decrypting a long string
+	for i := range n {
+		if got := capped.ByteAt(i); got != want[i] {
+			t.Fatalf("ByteAt(%d) = 0x%02X, want 0x%02X", i, got, want[i])
+		}
+	}
+
+	// going backwards past the cap restarts from the retained stream
+	for _, i := range []int{n - 1, 2*wz.KeyBatchSize + 5, wz.KeyBatchSize, 7} {
+		if got := capped.ByteAt(i); got != want[i] {
+			t.Errorf("ByteAt(%d) = 0x%02X, want 0x%02X", i, got, want[i])
+		}
+	}
+}
+
+// BenchmarkKey_LongString decrypts a 4 MiB keyed string with a fresh Key,
+// as a long String.wz entry would, with and without a retention cap.
+// retained-B is the heap the Key still holds afterwards.
+func BenchmarkKey_LongString(b *testing.B) {
+	iv := [4]byte{0x4D, 0x23, 0xC7, 0x2B}
+	encrypted := make([]byte, 4<<20)
+
+	for _, limit := range []int{0, 64 << 10} {
+		b.Run(fmt.Sprintf("max_retained=%d", limit), func(b *testing.B) {
+			b.SetBytes(int64(len(encrypted)))
+			b.ReportAllocs()
+
+			for b.Loop() {
+				k := wz.NewKey(iv)
+				k.SetMaxRetained(limit)
+				k.DecryptKeyedString(encrypted, false)
+			}
+
+			b.ReportMetric(float64(retainedBy(func() any {
+				k := wz.NewKey(iv)
+				k.SetMaxRetained(limit)
+				k.DecryptKeyedString(encrypted, false)
+				return k
+			})), "retained-B")
+		})
+	}
+}
+
+// retainedBy returns how many heap bytes the value returned by fn keeps
+// alive once garbage is collected.
+func retainedBy(fn func() any) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	v := fn()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(v)
+
+	if after.HeapAlloc < before.HeapAlloc {
+		return 0
+	}
+	return after.HeapAlloc - before.HeapAlloc
+}
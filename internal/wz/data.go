@@ -33,6 +33,63 @@ func ReadCompressedInt32(r io.Reader, x *int32) error {
 	return nil
 }
 
+// ReadCompressedInt64 reads a WZ compressed long from r.
+// It is the 64-bit counterpart of ReadCompressedInt32, used by
+// long properties:
+//   - The first byte is always an int8. If its value fits
+//     in the range [-127, 127], then it is the value of the
+//     compressed long.
+//   - If the first byte is exactly -128, then the next
+//     8 bytes are a little-endian int64.
+//
+// Reference: MapleLib WzBinaryReader.ReadLong
+func ReadCompressedInt64(r io.Reader, x *int64) error {
+	var sb int8
+	if err := binary.Read(r, binary.LittleEndian, &sb); err != nil {
+		return fmt.Errorf("failed to read compressed long marker: %w", err)
+	}
+
+	if sb == -128 {
+		if err := binary.Read(r, binary.LittleEndian, x); err != nil {
+			return fmt.Errorf("failed to read compressed long value: %w", err)
+		}
+		return nil
+	}
+
+	*x = int64(sb)
+	return nil
+}
+
+// ReadCompressedFloat reads a WZ compressed float from r.
+// It is the float counterpart of the compressed int, used by
+// float properties:
+//   - If the first byte is exactly -128 (0x80), then the next
+//     4 bytes are a little-endian IEEE 754 float32.
+//   - Otherwise the value is 0 and no more bytes follow. The
+//     byte is 0 in practice, and zero floats are very common.
+//
+// Always reading 4 bytes would desync the stream after a zero float.
+//
+// Reference: MapleLib WzImageProperty.ParsePropertyList (float case)
+func ReadCompressedFloat(r io.Reader, f *float32) error {
+	var sb int8
+	if err := binary.Read(r, binary.LittleEndian, &sb); err != nil {
+		return fmt.Errorf("failed to read compressed float marker: %w", err)
+	}
+
+	if sb == -128 {
+		if err := binary.Read(r, binary.LittleEndian, f); err != nil {
+			return fmt.Errorf("failed to read compressed float value: %w", err)
+		}
+		return nil
+	}
+
+	// MapleLib only checks for 0x80, so any other marker is read as
+	// the zero sentinel rather than failing the whole image
+	*f = 0
+	return nil
+}
+
 // ReadEncryptedString reads and decrypts a WZ encrypted string from r.
 //
 // Format:
@@ -50,14 +107,39 @@ func ReadCompressedInt32(r io.Reader, x *int32) error {
 //
 // Reference: MapleLib WzBinaryReader.ReadString
 func ReadEncryptedString(r io.Reader, key *Key, str *string) error {
+	encrypted, isUnicode, err := readEncryptedStringBytes(r)
+	if err != nil {
+		return err
+	}
+
+	// Decrypt using the WZ key
+	*str = key.DecryptString(encrypted, isUnicode)
+	return nil
+}
+
+// ReadKeyedEncryptedString is like ReadEncryptedString, but decrypts
+// with Key.DecryptKeyedString for strings that were also encrypted
+// with the key stream.
+func ReadKeyedEncryptedString(r io.Reader, key *Key, str *string) error {
+	encrypted, isUnicode, err := readEncryptedStringBytes(r)
+	if err != nil {
+		return err
+	}
+
+	*str = key.DecryptKeyedString(encrypted, isUnicode)
+	return nil
+}
+
+// readEncryptedStringBytes reads the still-encrypted data of a WZ string
+// (see ReadEncryptedString for the format) and whether it is Unicode.
+func readEncryptedStringBytes(r io.Reader) ([]byte, bool, error) {
 	var lengthIndicator int8
 	if err := binary.Read(r, binary.LittleEndian, &lengthIndicator); err != nil {
-		return fmt.Errorf("failed to read string length indicator: %w", err)
+		return nil, false, fmt.Errorf("failed to read string length indicator: %w", err)
 	}
 
 	if lengthIndicator == 0 {
-		*str = ""
-		return nil
+		return nil, false, nil
 	}
 
 	var length int32
@@ -72,7 +154,7 @@ func ReadEncryptedString(r io.Reader, key *Key, str *string) error {
 	case lengthIndicator == 127:
 		// Unicode string, long length
 		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
-			return fmt.Errorf("failed to read unicode string length: %w", err)
+			return nil, false, fmt.Errorf("failed to read unicode string length: %w", err)
 		}
 		isUnicode = true
 
@@ -84,13 +166,13 @@ func ReadEncryptedString(r io.Reader, key *Key, str *string) error {
 	case lengthIndicator == -128:
 		// ASCII string, long length
 		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
-			return fmt.Errorf("failed to read ascii string length: %w", err)
+			return nil, false, fmt.Errorf("failed to read ascii string length: %w", err)
 		}
 		isUnicode = false
 	}
 
 	if length < 0 {
-		return fmt.Errorf("invalid string length: %d", length)
+		return nil, false, fmt.Errorf("invalid string length: %d", length)
 	}
 
 	// Calculate byte length (Unicode uses 2 bytes per character)
@@ -102,16 +184,14 @@ func ReadEncryptedString(r io.Reader, key *Key, str *string) error {
 	// Read encrypted string data
 	encrypted := make([]byte, byteLength)
 	if _, err := io.ReadFull(r, encrypted); err != nil {
-		return fmt.Errorf("failed to read string data: %w", err)
+		return nil, false, fmt.Errorf("failed to read string data: %w", err)
 	}
 
-	// Decrypt using the WZ key
-	*str = key.DecryptString(encrypted, isUnicode)
-	return nil
+	return encrypted, isUnicode, nil
 }
 
 // ReadOffsetOrInlineString reads a string that may be stored inline or at an offset.
-// Used for directory entry names and property names in WZ files.
+// Used for property names and extended property type names inside WZ images.
 //
 // Format:
 //   1. Indicator byte:
@@ -119,26 +199,32 @@ func ReadEncryptedString(r io.Reader, key *Key, str *string) error {
 //      - 0x01 or 0x1B: String is at offset (next 4 bytes = int32 offset)
 //   2. String data (if inline) OR offset (if offset-based)
 //
+// Property names use 0x00/0x01 and extended type names (and the image
+// header) use 0x73/0x1B, but both pairs decode the same way. In particular,
+// offsets are always relative to imageOffset, the absolute file offset of
+// the image being read, never to the start of the file. This lets images
+// deduplicate strings within themselves.
+//
 // If the string is stored at an offset, this function:
 //   - Reads the int32 offset value
-//   - Seeks to that position in the file
+//   - Seeks to imageOffset + offset in the file
 //   - Reads the encrypted string
 //   - Seeks back to the original position (after indicator + offset bytes)
 //
 // Reference: MapleLib WzBinaryReader.ReadStringBlock
-func ReadOffsetOrInlineString(rs io.ReadSeeker, key *Key, str *string) error {
+func ReadOffsetOrInlineString(rs io.ReadSeeker, key *Key, imageOffset int64, str *string) error {
 	var indicator byte
 	if err := binary.Read(rs, binary.LittleEndian, &indicator); err != nil {
 		return fmt.Errorf("failed to read string indicator: %w", err)
 	}
 
 	switch indicator {
-	case 0x00, 0x73:
+	case StringInline, StringInlineExtended:
 		// String follows inline
 		return ReadEncryptedString(rs, key, str)
 
-	case 0x01, 0x1B:
-		// String is at an offset
+	case StringOffset, StringOffsetExtended:
+		// String is at an offset relative to the image
 		var offset int32
 		if err := binary.Read(rs, binary.LittleEndian, &offset); err != nil {
 			return fmt.Errorf("failed to read string offset: %w", err)
@@ -155,13 +241,14 @@ func ReadOffsetOrInlineString(rs io.ReadSeeker, key *Key, str *string) error {
 		}()
 
 		// Seek to string location
-		if _, err := rs.Seek(int64(offset), io.SeekStart); err != nil {
-			return fmt.Errorf("failed to seek to string at offset %d: %w", offset, err)
+		stringPos := imageOffset + int64(offset)
+		if _, err := rs.Seek(stringPos, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek to string at offset %d: %w", stringPos, err)
 		}
 
 		// Read and decrypt the string
 		if err := ReadEncryptedString(rs, key, str); err != nil {
-			return fmt.Errorf("failed to read string at offset %d: %w", offset, err)
+			return fmt.Errorf("failed to read string at offset %d: %w", stringPos, err)
 		}
 
 		return nil
@@ -181,11 +268,12 @@ func ReadOffsetOrInlineString(rs io.ReadSeeker, key *Key, str *string) error {
 //   - r: Reader positioned at the encrypted offset
 //   - bodyOffset: Where WZ data begins (from file header)
 //   - versionHash: Hash calculated from MapleStory version (e.g., "263" → 54036)
+//   - offsetConstant: See DecryptOffset (usually OffsetConstant)
 //   - offset: Output - decrypted absolute file offset
 //
 // The decryption uses bitwise operations (XOR, rotation) and the version hash.
 // See DecryptOffset in crypto.go for the full algorithm.
-func ReadEncryptedOffset(r io.ReadSeeker, bodyOffset uint32, versionHash uint32, offset *uint32) error {
+func ReadEncryptedOffset(r io.ReadSeeker, bodyOffset uint32, versionHash uint32, offsetConstant uint32, offset *uint32) error {
 	// Get current position before reading the encrypted offset
 	currentPos, err := r.Seek(0, io.SeekCurrent)
 	if err != nil {
@@ -199,6 +287,6 @@ func ReadEncryptedOffset(r io.ReadSeeker, bodyOffset uint32, versionHash uint32,
 	}
 
 	// Decrypt and store in output parameter
-	*offset = DecryptOffset(uint32(currentPos), bodyOffset, versionHash, encryptedOffset)
+	*offset = DecryptOffset(uint32(currentPos), bodyOffset, versionHash, offsetConstant, encryptedOffset)
 	return nil
 }
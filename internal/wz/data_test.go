@@ -0,0 +1,190 @@
+package wz_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+var gmsKey = wz.NewKey([4]byte{0x4D, 0x23, 0xC7, 0x2B})
+
+// encodeString returns s in the ReadEncryptedString format
+func encodeString(t *testing.T, s string) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := wz.WriteEncryptedString(buf, gmsKey, s); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadOffsetOrInlineString(t *testing.T) {
+	// Layout: [8 bytes padding][image start: "pooled" string][block under test][0xEE]
+	const imageOffset = 8
+
+	tests := []struct {
+		name      string
+		indicator byte
+	}{
+		{name: "property name inline", indicator: wz.StringInline},
+		{name: "property name at offset", indicator: wz.StringOffset},
+		{name: "extended type inline", indicator: wz.StringInlineExtended},
+		{name: "extended type at offset", indicator: wz.StringOffsetExtended},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			buf.Write(make([]byte, imageOffset))
+			buf.Write(encodeString(t, "pooled"))
+			blockPos := int64(buf.Len())
+
+			buf.WriteByte(tt.indicator)
+			want := "inline"
+			if tt.indicator == wz.StringOffset || tt.indicator == wz.StringOffsetExtended {
+				// relative to the image, not the file
+				binary.Write(buf, binary.LittleEndian, int32(0))
+				want = "pooled"
+			} else {
+				buf.Write(encodeString(t, "inline"))
+			}
+			buf.WriteByte(0xEE)
+
+			rs := bytes.NewReader(buf.Bytes())
+			rs.Seek(blockPos, io.SeekStart)
+
+			var got string
+			if err := wz.ReadOffsetOrInlineString(rs, gmsKey, imageOffset, &got); err != nil {
+				t.Fatalf("ReadOffsetOrInlineString() failed: %v", err)
+			}
+			if got != want {
+				t.Errorf("ReadOffsetOrInlineString() = %q, want %q", got, want)
+			}
+
+			next, err := rs.ReadByte()
+			if err != nil || next != 0xEE {
+				t.Errorf("reader not positioned after the string block (next byte 0x%02X, err %v)", next, err)
+			}
+		})
+	}
+}
+
+func TestReadOffsetOrInlineString_UnknownIndicator(t *testing.T) {
+	var got string
+	err := wz.ReadOffsetOrInlineString(bytes.NewReader([]byte{0x42}), gmsKey, 0, &got)
+	if err == nil || !strings.Contains(err.Error(), "unknown string indicator: 0x42") {
+		t.Errorf("ReadOffsetOrInlineString() error = %v, want unknown indicator error", err)
+	}
+}
+
+func TestReadCompressedInt64(t *testing.T) {
+	full := func(x int64) []byte {
+		return binary.LittleEndian.AppendUint64([]byte{0x80}, uint64(x))
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    int64
+		wantErr bool
+	}{
+		{name: "single byte", data: []byte{0x7F}, want: 127},
+		{name: "negative single byte", data: []byte{0x81}, want: -127},
+		{name: "full long", data: full(20200101000000), want: 20200101000000},
+		{name: "full long beyond 2^53", data: full(math.MaxInt64), want: math.MaxInt64},
+		{name: "negative full long", data: full(-1 << 40), want: -1 << 40},
+		{name: "truncated", data: []byte{0x80, 0x01, 0x02}, wantErr: true},
+		{name: "empty", data: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a trailing byte checks that exactly the long was consumed
+			r := bytes.NewReader(tt.data)
+			if !tt.wantErr {
+				r = bytes.NewReader(append(tt.data, 0xEE))
+			}
+
+			var got int64
+			err := wz.ReadCompressedInt64(r, &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+
+			next, err := r.ReadByte()
+			if err != nil || next != 0xEE {
+				t.Errorf("stream desynced: next byte %#x, err %v", next, err)
+			}
+		})
+	}
+}
+
+func TestReadCompressedFloat(t *testing.T) {
+	full := func(f float32) []byte {
+		b := []byte{0x80}
+		return binary.LittleEndian.AppendUint32(b, math.Float32bits(f))
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want float32
+	}{
+		{name: "zero marker", data: []byte{0x00}, want: 0},
+		{name: "other marker", data: []byte{0x05}, want: 0},
+		{name: "full float", data: full(1.5), want: 1.5},
+		{name: "negative full float", data: full(-0.25), want: -0.25},
+		{name: "full float zero", data: full(0), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a trailing byte checks that exactly the float was consumed
+			r := bytes.NewReader(append(tt.data, 0xEE))
+
+			var got float32
+			if err := wz.ReadCompressedFloat(r, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+
+			next, err := r.ReadByte()
+			if err != nil || next != 0xEE {
+				t.Errorf("stream desynced: next byte %#x, err %v", next, err)
+			}
+		})
+	}
+}
+
+func TestReadCompressedFloat_Truncated(t *testing.T) {
+	var f float32
+	if err := wz.ReadCompressedFloat(bytes.NewReader([]byte{0x80, 0x00}), &f); err == nil {
+		t.Error("expected error for truncated float")
+	}
+}
+
+func TestReadCompressedFloat_EOF(t *testing.T) {
+	var f float32
+	err := wz.ReadCompressedFloat(bytes.NewReader(nil), &f)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("ReadCompressedFloat() error = %v, want %v", err, io.EOF)
+	}
+}
@@ -0,0 +1,53 @@
+package wz
+
+import "strings"
+
+// KnownRootNames lists the top-level entry names of the standard GMS
+// data files: the .wz files of a split client (e.g. Mob.wz), and the
+// directories and images directly below their roots.
+//
+// Other regions use the same names. Newer clients split large files
+// into numbered continuations (Mob001.wz, Map002.wz), which IsKnownRoot
+// accepts too. Some regions also ship region-only files (e.g. KMS
+// test-server data) that aren't listed.
+var KnownRootNames = []string{
+	// split client files and Data.wz directories
+	"Base", "Character", "Effect", "Etc", "Item", "List", "Map", "Map2",
+	"Mob", "Mob2", "Morph", "Npc", "Quest", "Reactor", "Skill", "Sound",
+	"String", "TamingMob", "UI",
+
+	// Character.wz
+	"Accessory", "Afterimage", "Android", "Cap", "Cape", "Coat", "Dragon",
+	"Face", "Glove", "Hair", "Longcoat", "Mechanic", "Pants", "PetEquip",
+	"Ring", "Shield", "Shoes", "Weapon",
+
+	// Item.wz
+	"Cash", "Consume", "Install", "Pet", "Special",
+
+	// Map.wz
+	"Back", "Obj", "Tile", "WorldMap",
+
+	// String.wz images
+	"Eqp.img", "Ins.img", "Map.img", "Mob.img", "Npc.img", "Skill.img",
+}
+
+var knownRootNames = func() map[string]bool {
+	m := make(map[string]bool, len(KnownRootNames))
+	for _, name := range KnownRootNames {
+		m[name] = true
+	}
+	return m
+}()
+
+// IsKnownRoot reports whether name is one of KnownRootNames, ignoring a
+// numbered continuation suffix (Mob001 -> Mob) and a ".wz" extension,
+// so it also accepts file names such as "Mob001.wz".
+func IsKnownRoot(name string) bool {
+	name = strings.TrimSuffix(name, ".wz")
+	if knownRootNames[name] {
+		return true
+	}
+
+	base := strings.TrimRight(name, "0123456789")
+	return base != name && knownRootNames[base]
+}
@@ -0,0 +1,33 @@
+package wz_test
+
+import (
+	"testing"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+func TestIsKnownRoot(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "Mob", want: true},
+		{name: "Mob.wz", want: true},
+		{name: "Mob001", want: true},
+		{name: "Map002.wz", want: true},
+		{name: "Eqp.img", want: true},
+		{name: "Character", want: true},
+		{name: "Xq3", want: false},
+		{name: "Xq3.img", want: false},
+		{name: "001", want: false},
+		{name: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wz.IsKnownRoot(tt.name); got != tt.want {
+				t.Errorf("IsKnownRoot(%q) = %t, want %t", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package wz
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WriteCompressedInt32 writes x to w in the WZ compressed integer
+// format, exactly inverting ReadCompressedInt32:
+//   - Values in [-127, 127] are written as a single int8
+//   - Anything else is written as the -128 marker followed by
+//     a little-endian int32
+func WriteCompressedInt32(w io.Writer, x int32) error {
+	if x > math.MinInt8 && x <= math.MaxInt8 {
+		if err := binary.Write(w, binary.LittleEndian, int8(x)); err != nil {
+			return fmt.Errorf("failed to write compressed int value: %w", err)
+		}
+		return nil
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, int8(math.MinInt8)); err != nil {
+		return fmt.Errorf("failed to write compressed int marker: %w", err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, x); err != nil {
+		return fmt.Errorf("failed to write compressed int value: %w", err)
+	}
+	return nil
+}
+
+// WriteEncryptedString encrypts s and writes it to w in the
+// format read by ReadEncryptedString.
+func WriteEncryptedString(w io.Writer, key *Key, s string) error {
+	encrypted, isUnicode := key.EncryptString(s)
+
+	length := len(encrypted)
+	if isUnicode {
+		length /= 2
+	}
+
+	// Length indicator, see ReadEncryptedString
+	var header []byte
+	switch {
+	case length == 0:
+		header = []byte{0}
+	case isUnicode && length < 127:
+		header = []byte{byte(length)}
+	case isUnicode:
+		header = binary.LittleEndian.AppendUint32([]byte{127}, uint32(length))
+	case length < 128:
+		header = []byte{byte(-int8(length))}
+	default:
+		header = binary.LittleEndian.AppendUint32([]byte{0x80}, uint32(length))
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("failed to write string length: %w", err)
+	}
+	if _, err := w.Write(encrypted); err != nil {
+		return fmt.Errorf("failed to write string data: %w", err)
+	}
+	return nil
+}
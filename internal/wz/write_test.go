@@ -0,0 +1,49 @@
+package wz_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+func TestWriteCompressedInt32(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   int32
+		wantLen int
+	}{
+		{name: "zero", value: 0, wantLen: 1},
+		{name: "max single byte", value: 127, wantLen: 1},
+		{name: "min single byte", value: -127, wantLen: 1},
+		{name: "marker value", value: -128, wantLen: 5},
+		{name: "above single byte", value: 128, wantLen: 5},
+		{name: "below single byte", value: -129, wantLen: 5},
+		{name: "max int32", value: math.MaxInt32, wantLen: 5},
+		{name: "min int32", value: math.MinInt32, wantLen: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			if err := wz.WriteCompressedInt32(buf, tt.value); err != nil {
+				t.Fatalf("WriteCompressedInt32() failed: %v", err)
+			}
+			if buf.Len() != tt.wantLen {
+				t.Errorf("wrote %d bytes, want %d", buf.Len(), tt.wantLen)
+			}
+
+			var got int32
+			if err := wz.ReadCompressedInt32(buf, &got); err != nil {
+				t.Fatalf("ReadCompressedInt32() failed: %v", err)
+			}
+			if got != tt.value {
+				t.Errorf("round trip = %d, want %d", got, tt.value)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("%d bytes left unread", buf.Len())
+			}
+		})
+	}
+}
@@ -1,16 +1,150 @@
 package wz
 
+import "strings"
+
 // Header is the header of a WZ file.
 type Header struct {
 	Magic      [4]byte // "PKG1" for valid WZ files
 	BodySize   uint64  // size of data section (from BodyOffset to EOF)
 	BodyOffset uint32  // where the data section starts
-	Copyright  string
+	Copyright  string  // CopyrightRaw decoded as UTF-8 (best-effort)
+
+	// CopyrightRaw holds the copyright bytes up to the first NUL (or,
+	// if the header has none, the first control character), as non-GMS
+	// clients may use a non-ASCII (and non-UTF-8) encoding
+	CopyrightRaw []byte
 }
 
 type Dir struct {
+	Name            string // Directory name (empty for the root directory)
 	EntryCount      int32
 	EntriesMetadata []DirEntryMetadata
+	Subdirs         []*Dir // Subdirectories that have been read, in entry order
+}
+
+// Find returns the metadata of the entry with the given name.
+// The returned pointer refers into d.EntriesMetadata.
+func (d *Dir) Find(name string) (*DirEntryMetadata, bool) {
+	return d.find(func(n string) bool { return n == name })
+}
+
+// FindFold is like Find, but matches names case-insensitively.
+// WZ files are not consistent about casing (e.g. "Map.img" vs "map.img").
+func (d *Dir) FindFold(name string) (*DirEntryMetadata, bool) {
+	return d.find(func(n string) bool { return strings.EqualFold(n, name) })
+}
+
+// Subdir returns the subdirectory with the given name, if it has been read.
+func (d *Dir) Subdir(name string) (*Dir, bool) {
+	for _, sub := range d.Subdirs {
+		if sub.Name == name {
+			return sub, true
+		}
+	}
+	return nil, false
+}
+
+// WalkFunc is called by Dir.Walk for each entry. path is the
+// slash-separated path of the entry relative to the walked directory.
+// Returning an error stops the walk.
+type WalkFunc func(path string, entry *DirEntryMetadata) error
+
+// Walk calls fn for every entry below d in file order, descending into
+// a subdirectory right after its entry if it has been read.
+func (d *Dir) Walk(fn WalkFunc) error {
+	return d.walk("", fn)
+}
+
+func (d *Dir) walk(dirPath string, fn WalkFunc) error {
+	for i := range d.EntriesMetadata {
+		entry := &d.EntriesMetadata[i]
+
+		entryPath := entry.Name
+		if dirPath != "" {
+			entryPath = dirPath + "/" + entry.Name
+		}
+		if err := fn(entryPath, entry); err != nil {
+			return err
+		}
+
+		if entry.Type != DirEntryTypeDir {
+			continue
+		}
+		if sub, ok := d.Subdir(entry.Name); ok {
+			if err := sub.walk(entryPath, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Visitor holds the callbacks for Dir.Visit, which are passed the same
+// arguments as a WalkFunc. Nil callbacks are skipped, and returning an
+// error from any of them stops the walk.
+type Visitor struct {
+	// OnEnterDir is called for a directory entry before the entries below it
+	OnEnterDir WalkFunc
+	// OnLeaveDir is called for a directory entry after the entries below it,
+	// even if the subdirectory hasn't been read
+	OnLeaveDir WalkFunc
+	// OnImage is called for each image (file) entry
+	OnImage WalkFunc
+}
+
+// Visit walks d like Walk, but calls v's callbacks on entering and
+// leaving each directory, so nested output (e.g. XML elements) can be
+// written without buffering the tree.
+func (d *Dir) Visit(v Visitor) error {
+	return d.visit("", &v)
+}
+
+func (d *Dir) visit(dirPath string, v *Visitor) error {
+	call := func(fn WalkFunc, path string, entry *DirEntryMetadata) error {
+		if fn == nil {
+			return nil
+		}
+		return fn(path, entry)
+	}
+
+	for i := range d.EntriesMetadata {
+		entry := &d.EntriesMetadata[i]
+
+		entryPath := entry.Name
+		if dirPath != "" {
+			entryPath = dirPath + "/" + entry.Name
+		}
+
+		switch entry.Type {
+		case DirEntryTypeFile:
+			if err := call(v.OnImage, entryPath, entry); err != nil {
+				return err
+			}
+
+		case DirEntryTypeDir:
+			if err := call(v.OnEnterDir, entryPath, entry); err != nil {
+				return err
+			}
+			if sub, ok := d.Subdir(entry.Name); ok {
+				if err := sub.visit(entryPath, v); err != nil {
+					return err
+				}
+			}
+			if err := call(v.OnLeaveDir, entryPath, entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dir) find(match func(string) bool) (*DirEntryMetadata, bool) {
+	for i := range d.EntriesMetadata {
+		if match(d.EntriesMetadata[i].Name) {
+			return &d.EntriesMetadata[i], true
+		}
+	}
+	return nil, false
 }
 
 // DirEntryMetadata contains metadata for a single directory entry.
@@ -18,9 +152,15 @@ type Dir struct {
 type DirEntryMetadata struct {
 	Type       DirEntryType
 	Name       string // Entry name (decrypted)
+	NameKeyed  bool   // Name was encrypted with the key stream (see Key.DecryptKeyedString)
+	Region     string // Region of the fallback key that decrypted Name, if not the primary one
 	FileSize   int32  // Size in bytes
 	Checksum   int32  // Validation checksum
 	DataOffset uint32 // Absolute file offset to entry data (decrypted)
+
+	// Raw offset encryption inputs, kept for debugging (see DecryptOffset)
+	OffsetPos       uint32 // File position the encrypted offset was read from
+	EncryptedOffset uint32 // DataOffset as stored in the file
 }
 
 type DirEntryType byte
@@ -0,0 +1,206 @@
+package wz_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+	"github.com/ossyrian/mintyparse/internal/wztypes"
+)
+
+func TestDir_Find(t *testing.T) {
+	d := &wz.Dir{
+		EntriesMetadata: []wz.DirEntryMetadata{
+			{Type: wz.DirEntryTypeDir, Name: "Mob"},
+			{Type: wz.DirEntryTypeFile, Name: "Map.img"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		find     string
+		fold     bool
+		wantName string // empty for no match
+	}{
+		{name: "hit", find: "Map.img", wantName: "Map.img"},
+		{name: "miss", find: "Npc"},
+		{name: "empty name", find: ""},
+		{name: "case differs", find: "map.img"},
+		{name: "case folded", find: "map.IMG", fold: true, wantName: "Map.img"},
+		{name: "case folded miss", find: "Npc", fold: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			find := d.Find
+			if tt.fold {
+				find = d.FindFold
+			}
+
+			entry, ok := find(tt.find)
+			if ok != (tt.wantName != "") {
+				t.Fatalf("found = %v, want %v", ok, tt.wantName != "")
+			}
+			if ok && entry.Name != tt.wantName {
+				t.Errorf("found %q, want %q", entry.Name, tt.wantName)
+			}
+		})
+	}
+
+	t.Run("points into the directory", func(t *testing.T) {
+		entry, _ := d.Find("Mob")
+		if entry != &d.EntriesMetadata[0] {
+			t.Error("Find() returned a copy of the entry")
+		}
+	})
+}
+
+func TestWzImage_Get(t *testing.T) {
+	origin := &wztypes.WzVectorProperty{Name: "origin", X: 1, Y: 2}
+	icon := &wztypes.WzCanvasProperty{Name: "icon", Properties: []wztypes.WzProperty{origin}}
+	price := &wztypes.WzIntProperty{Name: "price", Value: 100}
+	info := &wztypes.WzSubProperty{Name: "info", Properties: []wztypes.WzProperty{icon, price}}
+	img := &wztypes.WzImage{Name: "Item.img", Properties: []wztypes.WzProperty{info}}
+
+	tests := []struct {
+		name string
+		path []string
+		fold bool
+		want wztypes.WzProperty // nil for no match
+	}{
+		{name: "hit", path: []string{"info"}, want: info},
+		{name: "miss", path: []string{"spec"}},
+		{name: "nested path", path: []string{"info", "price"}, want: price},
+		{name: "nested through a canvas", path: []string{"info", "icon", "origin"}, want: origin},
+		{name: "nested miss", path: []string{"info", "slotMax"}},
+		{name: "below a value", path: []string{"info", "price", "x"}},
+		{name: "empty path"},
+		{name: "case differs", path: []string{"Info", "Price"}},
+		{name: "case folded", path: []string{"Info", "PRICE"}, fold: true, want: price},
+		{name: "case folded empty path", fold: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			get := img.Get
+			if tt.fold {
+				get = img.GetFold
+			}
+
+			got, ok := get(tt.path...)
+			if ok != (tt.want != nil) {
+				t.Fatalf("found = %v, want %v", ok, tt.want != nil)
+			}
+			if ok && got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDir_Walk(t *testing.T) {
+	mob := &wz.Dir{
+		Name: "Mob",
+		EntriesMetadata: []wz.DirEntryMetadata{
+			{Type: wz.DirEntryTypeFile, Name: "0100100.img"},
+		},
+	}
+	root := &wz.Dir{
+		EntriesMetadata: []wz.DirEntryMetadata{
+			{Type: wz.DirEntryTypeDir, Name: "Mob"},
+			{Type: wz.DirEntryTypeDir, Name: "Unread"},
+			{Type: wz.DirEntryTypeFile, Name: "Foo.img"},
+		},
+		Subdirs: []*wz.Dir{mob},
+	}
+
+	var got []string
+	err := root.Walk(func(path string, entry *wz.DirEntryMetadata) error {
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() failed: %v", err)
+	}
+
+	want := []string{"Mob", "Mob/0100100.img", "Unread", "Foo.img"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk() visited %v, want %v", got, want)
+	}
+
+	t.Run("stops on error", func(t *testing.T) {
+		errStop := errors.New("stop")
+
+		var visited int
+		err := root.Walk(func(path string, entry *wz.DirEntryMetadata) error {
+			visited++
+			if path == "Mob/0100100.img" {
+				return errStop
+			}
+			return nil
+		})
+		if !errors.Is(err, errStop) {
+			t.Errorf("Walk() error = %v, want %v", err, errStop)
+		}
+		if visited != 2 {
+			t.Errorf("Walk() visited %d entries, want 2", visited)
+		}
+	})
+}
+
+func TestDir_Visit(t *testing.T) {
+	mob := &wz.Dir{
+		Name: "Mob",
+		EntriesMetadata: []wz.DirEntryMetadata{
+			{Type: wz.DirEntryTypeFile, Name: "0100100.img"},
+		},
+	}
+	root := &wz.Dir{
+		EntriesMetadata: []wz.DirEntryMetadata{
+			{Type: wz.DirEntryTypeDir, Name: "Mob"},
+			{Type: wz.DirEntryTypeDir, Name: "Unread"},
+			{Type: wz.DirEntryTypeFile, Name: "Foo.img"},
+		},
+		Subdirs: []*wz.Dir{mob},
+	}
+
+	var got []string
+	record := func(event string) wz.WalkFunc {
+		return func(path string, entry *wz.DirEntryMetadata) error {
+			got = append(got, event+" "+path)
+			return nil
+		}
+	}
+
+	err := root.Visit(wz.Visitor{
+		OnEnterDir: record("enter"),
+		OnLeaveDir: record("leave"),
+		OnImage:    record("image"),
+	})
+	if err != nil {
+		t.Fatalf("Visit() failed: %v", err)
+	}
+
+	want := []string{
+		"enter Mob",
+		"image Mob/0100100.img",
+		"leave Mob",
+		"enter Unread",
+		"leave Unread",
+		"image Foo.img",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Visit() events = %v, want %v", got, want)
+	}
+
+	t.Run("nil callbacks are skipped", func(t *testing.T) {
+		got = nil
+		if err := root.Visit(wz.Visitor{OnImage: record("image")}); err != nil {
+			t.Fatalf("Visit() failed: %v", err)
+		}
+		if want := []string{"image Mob/0100100.img", "image Foo.img"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Visit() events = %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,225 @@
+// Package wztest builds WZ files in memory for tests (and selfcheck),
+// using the same encryption and writing primitives the parser reads with.
+package wztest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ossyrian/mintyparse/internal/wz"
+)
+
+// Defaults used by BuildFile for unset Options fields.
+const (
+	DefaultRegion    = "gms"
+	DefaultVersion   = "83"
+	DefaultCopyright = "Package file v1.0 Copyright 2002 Wizet, ZZ"
+)
+
+// Options controls how BuildFile encodes a file.
+type Options struct {
+	Region    string // region whose key encrypts names (default DefaultRegion)
+	Version   string // MapleStory version the offsets are encrypted for (default DefaultVersion)
+	Copyright string // header copyright (default DefaultCopyright)
+
+	// NoVersionHeader omits the version header after the file header,
+	// as newer clients do
+	NoVersionHeader bool
+
+	// Images holds image contents by slash-separated path (e.g.
+	// "Mob/0100100.img"). Images not listed are written as FileSize
+	// zero bytes.
+	Images map[string][]byte
+}
+
+// BuildFile encodes a WZ file holding the directory tree root and
+// returns it along with the tree the parser should read back from it.
+//
+// root only needs entry types, names, sizes and checksums, plus a
+// Subdirs entry for any directory that should have entries of its own.
+// The returned tree is a copy of root with EntryCount, DataOffset and
+// the raw offset fields filled in, and an empty Dir for every
+// directory entry without one. root itself is not modified.
+//
+// Directories are stored before images, each directory after its
+// parent, so the file can also be read by parser.StreamReader.
+func BuildFile(root *wz.Dir, opts Options) (*bytes.Reader, *wz.Dir, error) {
+	if opts.Region == "" {
+		opts.Region = DefaultRegion
+	}
+	if opts.Version == "" {
+		opts.Version = DefaultVersion
+	}
+	if opts.Copyright == "" {
+		opts.Copyright = DefaultCopyright
+	}
+
+	ivBytes, err := wz.IVForVersion(opts.Region)
+	if err != nil {
+		return nil, nil, err
+	}
+	var iv [4]byte
+	copy(iv[:], ivBytes)
+
+	b := &builder{
+		opts:        opts,
+		key:         wz.NewKey(iv),
+		bodyOffset:  uint32(16 + len(opts.Copyright) + 1),
+		versionHash: wz.VersionHash(opts.Version),
+	}
+
+	want := cloneDir(root, "")
+	dirs, images := b.layout(want)
+
+	// Entry sizes don't depend on offset values, so lay the file out once
+	// to find where everything lands, then write it again with real offsets.
+	for range 2 {
+		b.buf.Reset()
+		b.writeHeader()
+
+		for _, d := range dirs {
+			if d.entry != nil {
+				d.entry.DataOffset = uint32(b.buf.Len())
+			}
+			if err := b.writeDir(d.dir); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		for _, img := range images {
+			img.entry.DataOffset = uint32(b.buf.Len())
+			b.buf.Write(img.data)
+		}
+	}
+
+	// patch in the body size now that the file is complete
+	file := b.buf.Bytes()
+	binary.LittleEndian.PutUint64(file[4:], uint64(len(file))-uint64(b.bodyOffset))
+
+	return bytes.NewReader(file), want, nil
+}
+
+// cloneDir deep-copies d, adding an empty Dir for every directory
+// entry that has none and setting EntryCount from the entries.
+func cloneDir(d *wz.Dir, name string) *wz.Dir {
+	c := &wz.Dir{
+		Name:       name,
+		EntryCount: int32(len(d.EntriesMetadata)),
+		// non-nil even if empty, as the parser allocates it up front
+		EntriesMetadata: append([]wz.DirEntryMetadata{}, d.EntriesMetadata...),
+	}
+
+	for _, entry := range d.EntriesMetadata {
+		if entry.Type != wz.DirEntryTypeDir {
+			continue
+		}
+		sub, ok := d.Subdir(entry.Name)
+		if !ok {
+			sub = &wz.Dir{}
+		}
+		c.Subdirs = append(c.Subdirs, cloneDir(sub, entry.Name))
+	}
+
+	return c
+}
+
+// placedDir is a directory in file order, with the parent entry that
+// points at it (nil for the root).
+type placedDir struct {
+	dir   *wz.Dir
+	entry *wz.DirEntryMetadata
+}
+
+// placedImage is an image in file order.
+type placedImage struct {
+	entry *wz.DirEntryMetadata
+	data  []byte
+}
+
+// layout returns the directories (breadth-first) and images (in walk
+// order) of root in the order they are written. Image entries' FileSize
+// is set from Options.Images where given.
+func (b *builder) layout(root *wz.Dir) ([]placedDir, []placedImage) {
+	dirs := []placedDir{{dir: root}}
+	var images []placedImage
+
+	for i := 0; i < len(dirs); i++ {
+		d := dirs[i].dir
+		for j := range d.EntriesMetadata {
+			entry := &d.EntriesMetadata[j]
+			if entry.Type == wz.DirEntryTypeDir {
+				sub, _ := d.Subdir(entry.Name)
+				dirs = append(dirs, placedDir{dir: sub, entry: entry})
+			}
+		}
+	}
+
+	root.Walk(func(path string, entry *wz.DirEntryMetadata) error {
+		if entry.Type != wz.DirEntryTypeFile {
+			return nil
+		}
+		data, ok := b.opts.Images[path]
+		if ok {
+			entry.FileSize = int32(len(data))
+		} else {
+			data = make([]byte, entry.FileSize)
+		}
+		images = append(images, placedImage{entry: entry, data: data})
+		return nil
+	})
+
+	return dirs, images
+}
+
+// builder encodes WZ file structures into an in-memory buffer.
+type builder struct {
+	buf         bytes.Buffer
+	opts        Options
+	key         *wz.Key
+	bodyOffset  uint32
+	versionHash uint32
+}
+
+// writeHeader writes the file header and version header; BodySize is
+// left as zero.
+func (b *builder) writeHeader() {
+	b.buf.Write(wz.Magic[:])
+	binary.Write(&b.buf, binary.LittleEndian, uint64(0))
+	binary.Write(&b.buf, binary.LittleEndian, b.bodyOffset)
+	b.buf.WriteString(b.opts.Copyright)
+	b.buf.WriteByte(0)
+
+	if !b.opts.NoVersionHeader {
+		binary.Write(&b.buf, binary.LittleEndian, wz.ObfuscateVersionHash(b.versionHash))
+	}
+}
+
+// writeDir writes the entry count and entries of d, recording the raw
+// offset fields the parser should report.
+func (b *builder) writeDir(d *wz.Dir) error {
+	if err := wz.WriteCompressedInt32(&b.buf, d.EntryCount); err != nil {
+		return err
+	}
+
+	for i := range d.EntriesMetadata {
+		entry := &d.EntriesMetadata[i]
+
+		b.buf.WriteByte(byte(entry.Type))
+		if err := wz.WriteEncryptedString(&b.buf, b.key, entry.Name); err != nil {
+			return fmt.Errorf("failed to write name %q: %w", entry.Name, err)
+		}
+		if err := wz.WriteCompressedInt32(&b.buf, entry.FileSize); err != nil {
+			return err
+		}
+		if err := wz.WriteCompressedInt32(&b.buf, entry.Checksum); err != nil {
+			return err
+		}
+
+		entry.OffsetPos = uint32(b.buf.Len())
+		entry.EncryptedOffset = wz.EncryptOffset(entry.OffsetPos, b.bodyOffset, b.versionHash, wz.OffsetConstant, entry.DataOffset)
+		binary.Write(&b.buf, binary.LittleEndian, entry.EncryptedOffset)
+	}
+
+	return nil
+}
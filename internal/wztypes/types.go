@@ -0,0 +1,288 @@
+// Package wztypes holds the contents of WZ images: the property tree
+// that a directory entry of type wz.DirEntryTypeFile points at.
+package wztypes
+
+import "strings"
+
+// WzImage is a parsed WZ image (a .img directory entry).
+type WzImage struct {
+	Name       string // Image name, from its directory entry
+	Offset     int64  // Absolute file offset the image starts at
+	Properties []WzProperty
+}
+
+// Get returns the property at path, where each element is the name of
+// a child of the property before it, e.g. Get("info", "icon", "origin").
+// Children are those of sub-properties, canvases and convexes.
+func (img *WzImage) Get(path ...string) (WzProperty, bool) {
+	return findProperty(img.Properties, path, func(n, name string) bool { return n == name })
+}
+
+// GetFold is like Get, but matches names case-insensitively, like
+// wz.Dir.FindFold.
+func (img *WzImage) GetFold(path ...string) (WzProperty, bool) {
+	return findProperty(img.Properties, path, strings.EqualFold)
+}
+
+func findProperty(props []WzProperty, path []string, match func(n, name string) bool) (WzProperty, bool) {
+	if len(path) == 0 {
+		return nil, false
+	}
+	for _, prop := range props {
+		if !match(prop.GetName(), path[0]) {
+			continue
+		}
+		if len(path) == 1 {
+			return prop, true
+		}
+		return findProperty(childProperties(prop), path[1:], match)
+	}
+	return nil, false
+}
+
+// childProperties returns the properties nested in prop, if any.
+func childProperties(prop WzProperty) []WzProperty {
+	switch p := prop.(type) {
+	case *WzSubProperty:
+		return p.Properties
+	case *WzCanvasProperty:
+		return p.Properties
+	case *WzConvexProperty:
+		return p.Properties
+	}
+	return nil
+}
+
+// WzProperty is a single named property of an image, or of a
+// property nested in it.
+type WzProperty interface {
+	GetName() string
+	GetType() PropertyType
+	// GetValue returns the property's value as a JSON-friendly Go value.
+	GetValue() any
+}
+
+// PropertyType identifies the concrete type of a WzProperty. It is not
+// the type byte stored in the file; several bytes can map to one type.
+type PropertyType byte
+
+const (
+	PropertyNull PropertyType = iota
+	PropertyShort
+	PropertyInt
+	PropertyLong
+	PropertyFloat
+	PropertyDouble
+	PropertyString
+	PropertySubProperty
+	PropertyCanvas
+	PropertyVector
+	PropertyConvex
+	PropertySound
+	PropertyUOL
+)
+
+var propertyTypeNames = [...]string{
+	PropertyNull:        "null",
+	PropertyShort:       "short",
+	PropertyInt:         "int",
+	PropertyLong:        "long",
+	PropertyFloat:       "float",
+	PropertyDouble:      "double",
+	PropertyString:      "string",
+	PropertySubProperty: "sub_property",
+	PropertyCanvas:      "canvas",
+	PropertyVector:      "vector",
+	PropertyConvex:      "convex",
+	PropertySound:       "sound",
+	PropertyUOL:         "uol",
+}
+
+func (t PropertyType) String() string {
+	if int(t) < len(propertyTypeNames) {
+		return propertyTypeNames[t]
+	}
+	return "unknown"
+}
+
+// WzNullProperty is a property with a name but no value.
+type WzNullProperty struct {
+	Name string
+}
+
+func (p *WzNullProperty) GetName() string       { return p.Name }
+func (p *WzNullProperty) GetType() PropertyType { return PropertyNull }
+func (p *WzNullProperty) GetValue() any         { return nil }
+
+// WzShortProperty is a 16-bit integer property.
+type WzShortProperty struct {
+	Name  string
+	Value int16
+}
+
+func (p *WzShortProperty) GetName() string       { return p.Name }
+func (p *WzShortProperty) GetType() PropertyType { return PropertyShort }
+func (p *WzShortProperty) GetValue() any         { return p.Value }
+
+// WzIntProperty is a 32-bit integer property.
+type WzIntProperty struct {
+	Name  string
+	Value int32
+}
+
+func (p *WzIntProperty) GetName() string       { return p.Name }
+func (p *WzIntProperty) GetType() PropertyType { return PropertyInt }
+func (p *WzIntProperty) GetValue() any         { return p.Value }
+
+// WzLongProperty is a 64-bit integer property. Values can exceed
+// 2^53, beyond what JSON consumers that use doubles represent exactly.
+type WzLongProperty struct {
+	Name  string
+	Value int64
+}
+
+func (p *WzLongProperty) GetName() string       { return p.Name }
+func (p *WzLongProperty) GetType() PropertyType { return PropertyLong }
+func (p *WzLongProperty) GetValue() any         { return p.Value }
+
+// WzFloatProperty is a 32-bit floating point property.
+type WzFloatProperty struct {
+	Name  string
+	Value float32
+}
+
+func (p *WzFloatProperty) GetName() string       { return p.Name }
+func (p *WzFloatProperty) GetType() PropertyType { return PropertyFloat }
+func (p *WzFloatProperty) GetValue() any         { return p.Value }
+
+// WzDoubleProperty is a 64-bit floating point property.
+type WzDoubleProperty struct {
+	Name  string
+	Value float64
+}
+
+func (p *WzDoubleProperty) GetName() string       { return p.Name }
+func (p *WzDoubleProperty) GetType() PropertyType { return PropertyDouble }
+func (p *WzDoubleProperty) GetValue() any         { return p.Value }
+
+// WzStringProperty is a string property.
+type WzStringProperty struct {
+	Name  string
+	Value string
+}
+
+func (p *WzStringProperty) GetName() string       { return p.Name }
+func (p *WzStringProperty) GetType() PropertyType { return PropertyString }
+func (p *WzStringProperty) GetValue() any         { return p.Value }
+
+// WzSubProperty is a nested property list (the "Property" extended type).
+type WzSubProperty struct {
+	Name       string
+	Properties []WzProperty
+}
+
+func (p *WzSubProperty) GetName() string       { return p.Name }
+func (p *WzSubProperty) GetType() PropertyType { return PropertySubProperty }
+
+// GetValue returns the values of the contained properties by name.
+func (p *WzSubProperty) GetValue() any {
+	return propertyValues(p.Properties)
+}
+
+// propertyValues returns the values of props by name.
+func propertyValues(props []WzProperty) map[string]any {
+	values := make(map[string]any, len(props))
+	for _, prop := range props {
+		values[prop.GetName()] = prop.GetValue()
+	}
+	return values
+}
+
+// WzCanvasProperty is an image (bitmap) property. The pixel data is
+// not decoded; DataOffset and DataSize locate it in the file.
+type WzCanvasProperty struct {
+	Name       string
+	Properties []WzProperty // child properties, e.g. origin and delay
+
+	Width, Height int32
+	Format        int32 // pixel format
+	Format2       byte  // scale, as a power of two
+
+	DataOffset int64 // absolute file offset of the compressed pixel data
+	DataSize   int32
+}
+
+func (p *WzCanvasProperty) GetName() string       { return p.Name }
+func (p *WzCanvasProperty) GetType() PropertyType { return PropertyCanvas }
+
+// GetValue returns the canvas dimensions and the values of its child
+// properties by name.
+func (p *WzCanvasProperty) GetValue() any {
+	return map[string]any{
+		"width":      p.Width,
+		"height":     p.Height,
+		"format":     p.Format,
+		"properties": propertyValues(p.Properties),
+	}
+}
+
+// WzVectorProperty is a 2D point, e.g. a canvas origin.
+type WzVectorProperty struct {
+	Name string
+	X, Y int32
+}
+
+func (p *WzVectorProperty) GetName() string       { return p.Name }
+func (p *WzVectorProperty) GetType() PropertyType { return PropertyVector }
+
+func (p *WzVectorProperty) GetValue() any {
+	return map[string]int32{"x": p.X, "y": p.Y}
+}
+
+// WzConvexProperty is a polygon: a list of points, usually
+// WzVectorProperty children named by their index.
+type WzConvexProperty struct {
+	Name       string
+	Properties []WzProperty
+}
+
+func (p *WzConvexProperty) GetName() string       { return p.Name }
+func (p *WzConvexProperty) GetType() PropertyType { return PropertyConvex }
+
+// GetValue returns the values of the contained properties, in order.
+func (p *WzConvexProperty) GetValue() any {
+	values := make([]any, len(p.Properties))
+	for i, prop := range p.Properties {
+		values[i] = prop.GetValue()
+	}
+	return values
+}
+
+// WzSoundProperty is an audio property. Only its size and duration
+// are read; the header and data are not.
+type WzSoundProperty struct {
+	Name     string
+	DataSize int32
+	Duration int32 // in milliseconds
+}
+
+func (p *WzSoundProperty) GetName() string       { return p.Name }
+func (p *WzSoundProperty) GetType() PropertyType { return PropertySound }
+
+func (p *WzSoundProperty) GetValue() any {
+	return map[string]any{
+		"size":        p.DataSize,
+		"duration_ms": p.Duration,
+	}
+}
+
+// WzUOLProperty is a link to another property, as a path relative to
+// the property's parent (e.g. "../stand/0").
+type WzUOLProperty struct {
+	Name  string
+	Value string
+}
+
+func (p *WzUOLProperty) GetName() string       { return p.Name }
+func (p *WzUOLProperty) GetType() PropertyType { return PropertyUOL }
+func (p *WzUOLProperty) GetValue() any         { return p.Value }
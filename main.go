@@ -1,19 +1,33 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/ossyrian/mintyparse/internal/archive"
 	"github.com/ossyrian/mintyparse/internal/config"
 	"github.com/ossyrian/mintyparse/internal/logging"
 	"github.com/ossyrian/mintyparse/internal/parser"
+	"github.com/ossyrian/mintyparse/internal/selfcheck"
+	"github.com/ossyrian/mintyparse/internal/writer"
+	"github.com/ossyrian/mintyparse/internal/wz"
+	"github.com/ossyrian/mintyparse/internal/wztypes"
 )
 
+// version is the mintyparse version, set at build time with
+// -ldflags "-X main.version=..."
+var version = "dev"
+
 var (
 	cfgFile string
 	cfg     *config.Config
@@ -26,35 +40,121 @@ var rootCmd = &cobra.Command{
 	RunE:  parse,
 }
 
+// selfcheckCmd parses a built-in synthetic WZ file to verify the environment
+var selfcheckCmd = &cobra.Command{
+	Use:           "selfcheck",
+	Short:         "Verify the parser by round-tripping a built-in synthetic WZ file",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runSelfcheck,
+}
+
+// validateCmd checks that the input decrypts without parsing all of it
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check that the input file decrypts, without parsing all of it",
+	Long: `Reads the header, determines the version and checks that the first few
+root directory entries decrypt to valid names. Exits non-zero if they
+don't. Useful as a quick check before a long extraction.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runValidate,
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
+	rootCmd.AddCommand(selfcheckCmd)
+	rootCmd.AddCommand(validateCmd)
+
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "path to config file")
 
 	// i/o
-	rootCmd.Flags().StringP("input", "i", "", "path to .wz file to parse (required)")
-	rootCmd.Flags().StringP("output", "o", "", "path to output JSON file")
+	rootCmd.PersistentFlags().StringP("input", "i", "", "path to .wz file (or zip archive, see --wz-entry) to parse (required)")
+	rootCmd.Flags().StringP("output", "o", "", "path to output JSON file (required unless --dry-run)")
+	rootCmd.PersistentFlags().String("output-format", "json", "output file format (json)")
 	rootCmd.Flags().StringP("sprites-output", "s", "", "directory to extract sprites to")
-	rootCmd.MarkFlagRequired("input")
-	rootCmd.MarkFlagRequired("output")
+	rootCmd.Flags().String("dump-tree-dir", "", "directory to mirror the WZ directory/image hierarchy into as empty directories")
+	rootCmd.Flags().Bool("keep-encrypted", false, "with --dump-tree-dir, also copy each image's raw encrypted bytes into a .enc file next to it")
+	rootCmd.Flags().Int("max-path", 0, "shorten written paths longer than this, listing them in path-map.tsv (0 for no cap)")
+	rootCmd.PersistentFlags().Bool("allow-remote", false, "allow --input to be an http(s) URL, fetched with range requests as it is read")
+	rootCmd.PersistentFlags().Duration("remote-timeout", 30*time.Second, "give up on an HTTP request for a URL input after this long")
+	rootCmd.PersistentFlags().String("wz-entry", "", "path of the .wz file inside the input zip archive (treats input as a zip)")
+	rootCmd.Flags().Bool("stream", false, "read the input front to back without seeking (input may be - for stdin); requires --game-version")
+	rootCmd.PersistentFlags().Int64("zip-memory-limit", 512<<20, "largest zip entry in bytes to read into memory; larger entries use a temp file")
+	rootCmd.PersistentFlags().Bool("prefetch", false, "read the whole input into memory before parsing (see --prefetch-limit)")
+	rootCmd.PersistentFlags().Int64("prefetch-limit", 1<<30, "largest input in bytes to prefetch; larger inputs are read from disk")
+	rootCmd.PersistentFlags().Bool("scan-magic", false, "search the start of the input for the WZ magic instead of expecting it at offset 0 (see --scan-magic-limit)")
+	rootCmd.PersistentFlags().Int64("scan-magic-limit", 1<<20, "how many bytes --scan-magic searches")
+	rootCmd.PersistentFlags().Int64("header-limit", 1<<20, "largest file header in bytes to accept; protected clients may pad it (0 for no cap)")
+	rootCmd.PersistentFlags().Int("key-memory-limit", 0, "largest encryption key stream in bytes to keep in memory; longer strings regenerate key bytes (0 for no cap)")
 
 	// game/format-specific settings
-	rootCmd.Flags().String("game-region", "gms", "MapleStory game region/edition (gms, kms, sea, tms)")
-	rootCmd.Flags().String("game-version", "", "MapleStory patch version number (e.g., 263, 230); if not provided, will bruteforce")
+	rootCmd.PersistentFlags().String("game-region", "gms", "MapleStory game region/edition (gms, kms, sea, tms); a comma-separated list (e.g. gms,sea) falls back to later regions for names the first can't decrypt")
+	rootCmd.PersistentFlags().String("game-version", "", "MapleStory patch version number (e.g., 263, 230); if not provided, will bruteforce")
+	rootCmd.PersistentFlags().StringSlice("version-range", nil, "version range start:end to bruteforce instead of the built-in ranges (repeatable, e.g. \"1:2000\")")
+	rootCmd.PersistentFlags().Duration("version-timeout", 0, "give up bruteforcing the version after this long (e.g. 30s); 0 for no limit")
+	rootCmd.PersistentFlags().Uint32("version-hash", 0, "version hash to decrypt offsets with (e.g. 0x754), bypassing --game-version and bruteforcing")
+	rootCmd.PersistentFlags().Uint32("offset-constant", 0, "constant used in offset decryption, for modified clients (default 0x581C3F6D)")
+	rootCmd.PersistentFlags().Bool("auto-region", false, "if the file doesn't decrypt with --game-region, retry with each other known region")
+	rootCmd.PersistentFlags().Bool("strict-root-names", false, "fail if a key-encrypted top-level name isn't a known WZ root name (catches a wrong --game-region)")
+	rootCmd.PersistentFlags().StringSlice("try-versions", nil, "literal version strings to try before the numeric ranges when bruteforcing (e.g. \"1163,1164,Rb\")")
 
 	// other opts
-	rootCmd.Flags().String("log-level", "info", "log level (trace, debug, info, warn, error, fatal)")
-	rootCmd.Flags().String("log-output-dir", "", "directory to write log files (if set, logs are written to both stdout and file)")
+	rootCmd.PersistentFlags().String("log-level", "info", "log level (trace, debug, info, warn, error, fatal)")
+	rootCmd.PersistentFlags().String("log-output-dir", "", "directory to write log files (if set, logs are written to both stdout and file)")
+	rootCmd.PersistentFlags().Bool("log-to-state", false, "write log files to $XDG_STATE_HOME/mintyparse/logs (default ~/.local/state) unless --log-output-dir is set")
 	rootCmd.Flags().Bool("dry-run", false, "parse without writing output (validation)")
+	rootCmd.Flags().Bool("count-only", false, "print how many directories, images, canvases and pixels the input holds instead of writing output")
+	rootCmd.PersistentFlags().Int("threads", 0, "number of images to parse in parallel (0 for auto, one per CPU)")
+	rootCmd.PersistentFlags().Bool("continue-on-error", false, "skip nodes that fail to parse and report them at the end instead of aborting")
 
-	viper.BindPFlag("input", rootCmd.Flags().Lookup("input"))
+	viper.BindPFlag("input", rootCmd.PersistentFlags().Lookup("input"))
 	viper.BindPFlag("output", rootCmd.Flags().Lookup("output"))
+	viper.BindPFlag("output_format", rootCmd.PersistentFlags().Lookup("output-format"))
 	viper.BindPFlag("sprites_dir", rootCmd.Flags().Lookup("sprites-output"))
-	viper.BindPFlag("game_region", rootCmd.Flags().Lookup("game-region"))
-	viper.BindPFlag("game_version", rootCmd.Flags().Lookup("game-version"))
-	viper.BindPFlag("log_level", rootCmd.Flags().Lookup("log-level"))
-	viper.BindPFlag("log_output_dir", rootCmd.Flags().Lookup("log-output-dir"))
+	viper.BindPFlag("dump_tree_dir", rootCmd.Flags().Lookup("dump-tree-dir"))
+	viper.BindPFlag("keep_encrypted", rootCmd.Flags().Lookup("keep-encrypted"))
+	viper.BindPFlag("max_path", rootCmd.Flags().Lookup("max-path"))
+	viper.BindPFlag("allow_remote", rootCmd.PersistentFlags().Lookup("allow-remote"))
+	viper.BindPFlag("remote_timeout", rootCmd.PersistentFlags().Lookup("remote-timeout"))
+	viper.BindPFlag("wz_entry", rootCmd.PersistentFlags().Lookup("wz-entry"))
+	viper.BindPFlag("stream", rootCmd.Flags().Lookup("stream"))
+	viper.BindPFlag("zip_memory_limit", rootCmd.PersistentFlags().Lookup("zip-memory-limit"))
+	viper.BindPFlag("prefetch", rootCmd.PersistentFlags().Lookup("prefetch"))
+	viper.BindPFlag("prefetch_limit", rootCmd.PersistentFlags().Lookup("prefetch-limit"))
+	viper.BindPFlag("scan_magic", rootCmd.PersistentFlags().Lookup("scan-magic"))
+	viper.BindPFlag("scan_magic_limit", rootCmd.PersistentFlags().Lookup("scan-magic-limit"))
+	viper.BindPFlag("header_limit", rootCmd.PersistentFlags().Lookup("header-limit"))
+	viper.BindPFlag("key_memory_limit", rootCmd.PersistentFlags().Lookup("key-memory-limit"))
+	viper.BindPFlag("game_region", rootCmd.PersistentFlags().Lookup("game-region"))
+	viper.BindPFlag("game_version", rootCmd.PersistentFlags().Lookup("game-version"))
+	viper.BindPFlag("version_ranges", rootCmd.PersistentFlags().Lookup("version-range"))
+	viper.BindPFlag("version_timeout", rootCmd.PersistentFlags().Lookup("version-timeout"))
+	viper.BindPFlag("version_hash", rootCmd.PersistentFlags().Lookup("version-hash"))
+	viper.BindPFlag("offset_constant", rootCmd.PersistentFlags().Lookup("offset-constant"))
+	viper.BindPFlag("auto_region", rootCmd.PersistentFlags().Lookup("auto-region"))
+	viper.BindPFlag("strict_root_names", rootCmd.PersistentFlags().Lookup("strict-root-names"))
+	viper.BindPFlag("try_versions", rootCmd.PersistentFlags().Lookup("try-versions"))
+	viper.BindPFlag("log_level", rootCmd.PersistentFlags().Lookup("log-level"))
+	viper.BindPFlag("log_output_dir", rootCmd.PersistentFlags().Lookup("log-output-dir"))
+	viper.BindPFlag("log_to_state", rootCmd.PersistentFlags().Lookup("log-to-state"))
 	viper.BindPFlag("dry_run", rootCmd.Flags().Lookup("dry-run"))
+	viper.BindPFlag("count_only", rootCmd.Flags().Lookup("count-only"))
+	viper.BindPFlag("threads", rootCmd.PersistentFlags().Lookup("threads"))
+	viper.BindPFlag("continue_on_error", rootCmd.PersistentFlags().Lookup("continue-on-error"))
+
+	// Bind every key to its MINTYPARSE_* env var up front (e.g.
+	// sprites_dir -> MINTYPARSE_SPRITES_DIR) so pure-env configuration
+	// works even when a key appears in neither a config file nor on the
+	// command line. Precedence is flag > env > config file > flag default.
+	viper.SetEnvPrefix("MINTYPARSE")
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_", ".", "_"))
+	for _, key := range viper.AllKeys() {
+		viper.BindEnv(key)
+	}
 }
 
 // initConfig reads in config file and environment variables if set
@@ -71,7 +171,6 @@ func initConfig() {
 		viper.SetConfigType("toml")
 	}
 
-	viper.SetEnvPrefix("MINTYPARSE")
 	viper.AutomaticEnv()
 
 	if err := viper.ReadInConfig(); err == nil {
@@ -79,39 +178,368 @@ func initConfig() {
 	}
 }
 
-// parse runs the main mintyparse command in order to parse
-// the specified WZ file
-func parse(cmd *cobra.Command, args []string) error {
+// loadConfig loads and validates cfg and sets up logging at logLevel,
+// or at cfg.LogLevel if logLevel is empty
+func loadConfig(logLevel string) error {
 	cfg = &config.Config{}
 	if err := viper.Unmarshal(cfg); err != nil {
 		return fmt.Errorf("invalid config: %w", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
 
-	if err := logging.Setup(cfg.LogLevel, cfg.LogOutputDir); err != nil {
+	// an explicit log directory wins over the state directory
+	if cfg.LogOutputDir == "" && cfg.LogToState {
+		dir, err := logging.StateLogDir()
+		if err != nil {
+			return err
+		}
+		cfg.LogOutputDir = dir
+	}
+
+	if logLevel == "" {
+		logLevel = cfg.LogLevel
+	}
+	if err := logging.Setup(logLevel, cfg.LogOutputDir); err != nil {
 		return fmt.Errorf("could not set up logging: %w", err)
 	}
 
+	return nil
+}
+
+// parse runs the main mintyparse command in order to parse
+// the specified WZ file
+func parse(cmd *cobra.Command, args []string) error {
+	if err := loadConfig(""); err != nil {
+		return err
+	}
+	if cfg.OutputFile == "" && !cfg.DryRun && !cfg.CountOnly && cfg.DumpTreeDir == "" {
+		return errors.New("invalid config: output is required unless dry_run, count_only or dump_tree_dir is set (--output or MINTYPARSE_OUTPUT)")
+	}
+
+	parsedAt := time.Now()
+
+	var result *parser.Result
+	var parseErr error
+	// raw is the seekable input, for copying out encrypted bytes
+	var raw io.ReadSeeker
+	if cfg.Stream {
+		result, parseErr = parseStream(cfg)
+	} else {
+		file, err := openInput(cfg)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		result, parseErr = parser.Parse(file, cfg)
+		if cfg.KeepEncrypted {
+			raw = file
+		}
+	}
+
+	logParseReport(cfg, result, parseErr, time.Since(parsedAt))
+
+	if parseErr != nil && result == nil {
+		return fmt.Errorf("failed to parse %s: %w", cfg.InputFile, parseErr)
+	}
+
+	// with continue_on_error, whatever was read is still written out
+	if err := writeResult(cfg, result, raw, parsedAt); err != nil {
+		return errors.Join(err, parseErr)
+	}
+	if parseErr != nil {
+		return fmt.Errorf("parsed %s with errors: %w", cfg.InputFile, parseErr)
+	}
+
+	return nil
+}
+
+// writeResult writes the outputs cfg asks for from a parsed result.
+// raw is the seekable input, or nil if encrypted bytes aren't kept.
+func writeResult(cfg *config.Config, result *parser.Result, raw io.ReadSeeker, parsedAt time.Time) error {
+	if cfg.CountOnly {
+		dirs, images, imageBytes := countEntries(result.Root)
+		canvases, pixels, err := countCanvases(cfg, result)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("directories=%d images=%d canvases=%d pixels=%d image_bytes=%d\n", dirs, images, canvases, pixels, imageBytes)
+		return nil
+	}
+
+	if cfg.DryRun {
+		return nil
+	}
+
+	if cfg.DumpTreeDir != "" {
+		if err := writer.DumpTreeDir(result.Root, cfg.DumpTreeDir, cfg.MaxPath, raw); err != nil {
+			return err
+		}
+	}
+	if cfg.OutputFile == "" {
+		return nil
+	}
+
+	// config.Validate has already rejected unsupported output formats
+	w := &writer.Writer{
+		Meta: writer.Meta{
+			Copyright:     result.Header.Copyright,
+			VersionHeader: result.VersionHeader,
+			Version:       result.Version,
+			VersionHash:   result.VersionHash,
+			Region:        cfg.GameRegion,
+			ToolVersion:   version,
+			ParsedAt:      parsedAt,
+		},
+	}
+	if err := writeOutput(cfg.OutputFile, w); err != nil {
+		return err
+	}
+
+	// TODO: output JSON for the parsed tree
+	// TODO: output sprites
+
+	return nil
+}
+
+// logParseReport logs a final structured summary of the parse, for
+// ingesting runs into monitoring from the JSON log file. It is logged
+// even if the parse failed, with whatever was read before the error.
+func logParseReport(cfg *config.Config, result *parser.Result, parseErr error, duration time.Duration) {
+	var version string
+	var dirs, images, failed int
+	var imageBytes int64
+	if result != nil {
+		version = result.Version
+		dirs, images, imageBytes = countEntries(result.Root)
+	}
+
+	var errMsg string
+	if parseErr != nil {
+		errMsg = parseErr.Error()
+		failed = 1
+		if joined, ok := parseErr.(interface{ Unwrap() []error }); ok {
+			failed = len(joined.Unwrap())
+		}
+	}
+
+	slog.Info("parse report",
+		"file", cfg.InputFile,
+		"region", cfg.GameRegion,
+		"version", version,
+		"ok", parseErr == nil,
+		slog.Group("counts",
+			"directories", dirs,
+			"images", images,
+			"image_bytes", imageBytes,
+			"errors", failed,
+		),
+		"duration", duration,
+		"error", errMsg,
+	)
+}
+
+// countEntries returns how many directories and images are below root,
+// and the total size the images declare
+func countEntries(root *wz.Dir) (dirs, images int, imageBytes int64) {
+	root.Walk(func(path string, entry *wz.DirEntryMetadata) error {
+		switch entry.Type {
+		case wz.DirEntryTypeDir:
+			dirs++
+		case wz.DirEntryTypeFile:
+			images++
+			imageBytes += int64(entry.FileSize)
+		}
+		return nil
+	})
+	return dirs, images, imageBytes
+}
+
+// countCanvases reads every image below result.Root and returns how many
+// canvases they hold and their total width*height. With
+// continue_on_error, images that fail to read are logged and skipped.
+func countCanvases(cfg *config.Config, result *parser.Result) (canvases int, pixels int64, err error) {
+	err = result.Root.Walk(func(path string, entry *wz.DirEntryMetadata) error {
+		if entry.Type != wz.DirEntryTypeFile {
+			return nil
+		}
+
+		img, err := result.ReadImage(entry)
+		if err != nil {
+			if !cfg.ContinueOnError {
+				return fmt.Errorf("failed to count canvases in %s: %w", path, err)
+			}
+			slog.Warn("skipping image that failed to read",
+				"path", path,
+				"error", err)
+			return nil
+		}
+
+		c, p := canvasTotals(img.Properties)
+		canvases += c
+		pixels += p
+		return nil
+	})
+	return canvases, pixels, err
+}
+
+// canvasTotals returns how many canvases are in props, including nested
+// ones, and their total width*height
+func canvasTotals(props []wztypes.WzProperty) (canvases int, pixels int64) {
+	for _, prop := range props {
+		var children []wztypes.WzProperty
+		switch p := prop.(type) {
+		case *wztypes.WzCanvasProperty:
+			canvases++
+			pixels += int64(p.Width) * int64(p.Height)
+			children = p.Properties
+		case *wztypes.WzSubProperty:
+			children = p.Properties
+		case *wztypes.WzConvexProperty:
+			children = p.Properties
+		}
+
+		c, px := canvasTotals(children)
+		canvases += c
+		pixels += px
+	}
+	return canvases, pixels
+}
+
+// writeOutput writes the JSON output to path, creating its
+// parent directory if needed
+func writeOutput(path string, w *writer.Writer) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	if err := w.Write(out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// parseStream parses the input as a forward-only stream,
+// reading from stdin if the input is "-"
+func parseStream(cfg *config.Config) (*parser.Result, error) {
+	if cfg.InputFile == "-" {
+		return parser.ParseStream(os.Stdin, cfg)
+	}
+
 	file, err := os.Open(cfg.InputFile)
 	if err != nil {
-		return fmt.Errorf("failed to open WZ file: %w", err)
+		return nil, fmt.Errorf("failed to open WZ file: %w", err)
 	}
 	defer file.Close()
 
-	if err := parser.Parse(file, cfg); err != nil {
-		slog.Error("error parsing file",
-			"file", cfg.InputFile,
-			"error", err,
-		)
+	return parser.ParseStream(file, cfg)
+}
 
-		return nil
+// runSelfcheck runs the selfcheck command, printing OK or FAIL
+func runSelfcheck(cmd *cobra.Command, args []string) error {
+	// only surface problems; the parse itself logs at info level
+	if err := logging.Setup("warn", ""); err != nil {
+		return fmt.Errorf("could not set up logging: %w", err)
 	}
 
-	// TODO: output JSON
-	// TODO: output sprites
+	if err := selfcheck.Run(); err != nil {
+		fmt.Println("FAIL")
+		return fmt.Errorf("selfcheck failed: %w", err)
+	}
+
+	fmt.Println("OK")
+	return nil
+}
+
+// runValidate runs the validate command, printing the detected
+// region and version on success
+func runValidate(cmd *cobra.Command, args []string) error {
+	if err := loadConfig(""); err != nil {
+		return err
+	}
+
+	file, err := openInput(cfg)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	result, err := parser.Validate(file, cfg)
+	if err != nil {
+		fmt.Println("FAIL")
+		return fmt.Errorf("validation failed: %w", err)
+	}
 
+	fmt.Printf("OK region=%s version=%s version_hash=%d\n",
+		cfg.GameRegion, result.Version, result.VersionHash)
 	return nil
 }
 
+// openInput opens the WZ file named by the config, fetching it
+// over HTTP if it is a URL, extracting it from a zip archive
+// first if cfg.WzEntry is set, reading
+// it into memory if cfg.Prefetch is set, and skipping to the
+// WZ magic if cfg.ScanMagic is set
+func openInput(cfg *config.Config) (io.ReadSeekCloser, error) {
+	var file io.ReadSeekCloser
+	if cfg.RemoteInput() {
+		client := &http.Client{Timeout: cfg.RemoteTimeout}
+		f, err := archive.OpenURL(client, cfg.InputFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open remote WZ file: %w", err)
+		}
+		file = f
+	} else if cfg.WzEntry != "" {
+		f, err := archive.OpenFromZip(cfg.InputFile, cfg.WzEntry, cfg.ZipMemoryLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open WZ file from zip: %w", err)
+		}
+		file = f
+	} else {
+		f, err := os.Open(cfg.InputFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open WZ file: %w", err)
+		}
+		file = f
+	}
+
+	if cfg.Prefetch {
+		prefetched, ok, err := archive.Prefetch(file, cfg.PrefetchLimit)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if !ok {
+			slog.Warn("input is larger than the prefetch limit, reading from disk",
+				"file", cfg.InputFile,
+				"prefetch_limit", cfg.PrefetchLimit)
+		}
+		file = prefetched
+	}
+
+	if cfg.ScanMagic {
+		view, offset, err := archive.ScanMagic(file, wz.Magic[:], cfg.ScanMagicLimit)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to find WZ magic: %w", err)
+		}
+		slog.Info("found WZ magic",
+			"file", cfg.InputFile,
+			"offset", offset)
+		file = view
+	}
+
+	return file, nil
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ossyrian/mintyparse/internal/parser"
+	"github.com/ossyrian/mintyparse/internal/server"
+)
+
+// serveCmd parses the directory tree and serves it as a JSON API
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the directory tree of the input file as a JSON API",
+	Long: `Parses the directory tree of the input file once, then serves it over
+HTTP for asset browsers:
+
+  GET /node/<path>    the directory at path (e.g. /node/Mob) as JSON, or
+                      the image or property at path (e.g.
+                      /node/Mob/0100100.img/info), read on request
+
+Canvas pixel data is not decoded yet, so /sprite/<path> returns
+501 Not Implemented.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runServe,
+}
+
+// Timeouts for serve's HTTP server, so slow or idle clients can't hold
+// connections open forever
+const (
+	serveReadHeaderTimeout = 10 * time.Second
+	serveReadTimeout       = 30 * time.Second
+	serveWriteTimeout      = time.Minute // large images take a while to read
+	serveIdleTimeout       = 2 * time.Minute
+)
+
+func init() {
+	serveCmd.Flags().String("addr", "localhost:8080", "address to listen on")
+
+	rootCmd.AddCommand(serveCmd)
+}
+
+// runServe runs the serve command
+func runServe(cmd *cobra.Command, args []string) error {
+	if err := loadConfig(""); err != nil {
+		return err
+	}
+	addr, _ := cmd.Flags().GetString("addr")
+	if cfg.AutoRegion {
+		return errors.New("serve doesn't support auto_region; set game_region to the file's region")
+	}
+
+	file, err := openInput(cfg)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// the reader stays open to read images as they are requested
+	reader, err := parser.Open(file, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to parse WZ file: %w", err)
+	}
+	root, err := reader.ReadTree()
+	if err != nil {
+		return fmt.Errorf("failed to parse WZ file: %w", err)
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           server.Handler(slog.Default(), root, reader),
+		ReadHeaderTimeout: serveReadHeaderTimeout,
+		ReadTimeout:       serveReadTimeout,
+		WriteTimeout:      serveWriteTimeout,
+		IdleTimeout:       serveIdleTimeout,
+	}
+
+	slog.Info("serving directory tree",
+		"file", cfg.InputFile,
+		"addr", addr)
+	return srv.ListenAndServe()
+}